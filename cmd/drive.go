@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ethpandaops/stubbies/pkg/drive"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var driveCfg drive.Config
+
+var driveCmd = &cobra.Command{
+	Use:   "drive",
+	Short: "Drive a real execution client as a minimal consensus client",
+	Long: `Drive a real execution client via the engine api, acting as a minimal consensus client.
+
+In build mode the execution client is asked to build a block every slot (forkchoiceUpdated with
+payload attributes, getPayload, newPayload, forkchoiceUpdated). In replay mode the newPayload and
+forkchoiceUpdated calls of a capture file are sent one slot at a time. Latency and status of every
+call is summarised on exit.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		log.SetFormatter(&logrus.TextFormatter{})
+
+		logLevel, err := logrus.ParseLevel(driveLogLevel)
+		if err != nil {
+			log.WithField("logLevel", driveLogLevel).Fatal("invalid logging level")
+		}
+
+		log.SetLevel(logLevel)
+
+		d, err := drive.NewDriver(log, &driveCfg)
+		if err != nil {
+			log.WithError(err).Fatal("invalid drive config")
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if err := d.Run(ctx); err != nil {
+			log.WithError(err).Fatal("failed to drive execution client")
+		}
+	},
+}
+
+var driveLogLevel string
+
+func init() {
+	rootCmd.AddCommand(driveCmd)
+
+	flags := driveCmd.Flags()
+	flags.StringVar(&driveCfg.Upstream.Target, "target", "http://127.0.0.1:8551", "engine api endpoint of the execution client")
+	flags.StringVar(&driveCfg.Upstream.JWTSecret, "jwt-secret", "", "hex encoded jwt secret of the execution client")
+	flags.StringVar(&driveCfg.Upstream.JWTSecretFile, "jwt-secret-file", "", "path to the jwt secret of the execution client")
+	flags.DurationVar(&driveCfg.Upstream.Timeout, "timeout", 12*time.Second, "timeout of a single engine api call")
+	flags.StringVar(&driveCfg.Mode, "mode", drive.ModeBuild, "build: have the execution client build a block every slot, replay: send the payloads of a capture file")
+	flags.StringVar(&driveCfg.CapturePath, "capture", "", "capture file to replay in replay mode")
+	flags.DurationVar(&driveCfg.SlotDuration, "slot-duration", 12*time.Second, "time between slots")
+	flags.IntVar(&driveCfg.Slots, "slots", 0, "number of slots to drive, 0 runs until interrupted")
+	flags.DurationVar(&driveCfg.BuildTime, "build-time", time.Second, "time the execution client gets to build a payload in build mode")
+	flags.IntVar(&driveCfg.EngineVersion, "engine-version", 3, "engine api method version used in build mode (1: paris, 2: shanghai, 3: cancun)")
+	flags.StringVar(&driveCfg.FeeRecipient, "fee-recipient", "0x0000000000000000000000000000000000000000", "fee recipient of built blocks")
+	flags.StringVar(&driveLogLevel, "logging", "info", "logging level (panic,fatal,warn,info,debug,trace)")
+}
@@ -3,6 +3,8 @@ package cmd
 import (
 	"context"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/creasty/defaults"
 	"github.com/ethpandaops/stubbies/pkg/server"
@@ -17,8 +19,16 @@ var rootCmd = &cobra.Command{
 	Short: "Ethereum execution client stub for consensus layer clients",
 	Run: func(cmd *cobra.Command, args []string) {
 		cfg := initCommon()
-		p := server.NewServer(log, cfg)
-		if err := p.Start(context.Background()); err != nil {
+
+		p, err := server.NewServer(log, cfg)
+		if err != nil {
+			log.WithError(err).Fatal("failed to create server")
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if err := p.Start(ctx); err != nil {
 			log.WithError(err).Fatal("failed to serve")
 		}
 	},
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	exec "github.com/ethpandaops/stubbies/pkg/execution"
+)
+
+// Activity is a summary of a single handled engine api call.
+type Activity struct {
+	Time        time.Time `json:"time"`
+	Tenant      string    `json:"tenant,omitempty"`
+	Client      string    `json:"client"`
+	Method      string    `json:"method"`
+	ID          int       `json:"id"`
+	BlockHash   string    `json:"blockHash,omitempty"`
+	BlockNumber string    `json:"blockNumber,omitempty"`
+	Status      string    `json:"status,omitempty"`
+	LatencyMS   float64   `json:"latencyMs"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// ActivityFilter selects activity by method prefix and client. Empty lists match everything.
+type ActivityFilter struct {
+	Methods []string
+	Clients []string
+}
+
+func (f *ActivityFilter) matches(a *Activity) bool {
+	if len(f.Methods) > 0 {
+		matched := false
+
+		for _, method := range f.Methods {
+			if strings.HasPrefix(a.Method, method) {
+				matched = true
+
+				break
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	if len(f.Clients) > 0 {
+		for _, client := range f.Clients {
+			if a.Client == client {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return true
+}
+
+func newActivity(start time.Time, from caller, body *JSONRequestBody, resp *exec.Response, err error) *Activity {
+	a := &Activity{
+		Time:      start.UTC(),
+		Client:    from.client,
+		Method:    body.Method,
+		ID:        body.ID,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+
+	a.BlockHash, a.BlockNumber = exec.RequestBlock(body.Method, body.Params)
+
+	if status, ok := exec.ResponsePayloadStatus(body.Method, resp); ok {
+		a.Status = status.Status
+	}
+
+	switch {
+	case err != nil:
+		a.Error = err.Error()
+	case resp != nil && resp.Error != nil:
+		a.Error = resp.Error.Message
+	}
+
+	return a
+}
+
+type activitySubscription struct {
+	ch     chan *Activity
+	filter ActivityFilter
+}
+
+// activityFeed fans out activity to subscribers. Slow subscribers miss activity rather than block requests.
+type activityFeed struct {
+	subs   map[int]*activitySubscription
+	next   int
+	closed bool
+
+	mu sync.Mutex
+}
+
+func newActivityFeed() *activityFeed {
+	return &activityFeed{
+		subs: make(map[int]*activitySubscription),
+	}
+}
+
+// Subscribe returns a channel receiving matching activity. The channel is closed on unsubscribe or when the feed is closed.
+func (f *activityFeed) Subscribe(filter ActivityFilter) (<-chan *Activity, func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ch := make(chan *Activity, 256)
+
+	if f.closed {
+		close(ch)
+
+		return ch, func() {}
+	}
+
+	id := f.next
+	f.next++
+
+	f.subs[id] = &activitySubscription{ch: ch, filter: filter}
+
+	return ch, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		if _, exists := f.subs[id]; exists {
+			delete(f.subs, id)
+			close(ch)
+		}
+	}
+}
+
+func (f *activityFeed) Publish(a *Activity) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, sub := range f.subs {
+		if !sub.filter.matches(a) {
+			continue
+		}
+
+		select {
+		case sub.ch <- a:
+		default:
+		}
+	}
+}
+
+// Close closes all subscriptions.
+func (f *activityFeed) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+
+	for id, sub := range f.subs {
+		delete(f.subs, id)
+		close(sub.ch)
+	}
+}
+
+// SubscribeActivity returns a channel receiving a summary of every handled call matching filter.
+func (h *Handler) SubscribeActivity(filter ActivityFilter) (<-chan *Activity, func()) {
+	return h.activity.Subscribe(filter)
+}
@@ -0,0 +1,269 @@
+package api
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	exec "github.com/ethpandaops/stubbies/pkg/execution"
+	"github.com/ethpandaops/stubbies/pkg/jwt"
+)
+
+// Sources a client identity can be derived from, in order of precedence.
+const (
+	ClientSourceJWT           = "jwt"
+	ClientSourceClientVersion = "client_version"
+	ClientSourceUserAgent     = "user_agent"
+	ClientSourceRemoteAddr    = "remote_addr"
+)
+
+// ClientUnauthenticated is the client id of requests failing authentication, whatever they claim to be.
+const ClientUnauthenticated = "unauthenticated"
+
+// ClientUnknown is the client label of metrics for callers identified by their User-Agent or remote address,
+// which they can choose freely.
+const ClientUnknown = "unknown"
+
+// maxClients bounds the clients, and reported client names, remembered. The least recently seen are forgotten first.
+const maxClients = 256
+
+var invalidClientChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// caller identifies where a request came from.
+type caller struct {
+	remoteAddr string
+	userAgent  string
+	client     string
+	// label is the client label of metrics.
+	label string
+}
+
+// ClientState is what stubbies knows about a single consensus client.
+type ClientState struct {
+	ID       string                `json:"id"`
+	Source   string                `json:"source"`
+	Version  *exec.ClientVersionV1 `json:"version,omitempty"`
+	LastCall time.Time             `json:"lastCall"`
+	// LastMethod is the method of the last request.
+	LastMethod string `json:"lastMethod"`
+	// LastHead and LastFinalized are the block hashes of the last forkchoice update.
+	LastHead             string    `json:"lastHead,omitempty"`
+	LastFinalized        string    `json:"lastFinalized,omitempty"`
+	LastForkchoiceUpdate time.Time `json:"lastForkchoiceUpdate"`
+}
+
+// clientRegistry identifies the consensus clients calling stubbies and tracks their state.
+type clientRegistry struct {
+	mu      sync.Mutex
+	clients map[string]*ClientState
+	// reported maps remote hosts and user agents to the client name they reported via engine_getClientVersionV1.
+	reported map[string]reportedClient
+	// forget is called with the id of clients labelled by id when they are forgotten.
+	forget func(id string)
+}
+
+type reportedClient struct {
+	name string
+	at   time.Time
+}
+
+func newClientRegistry(forget func(id string)) *clientRegistry {
+	return &clientRegistry{
+		clients:  make(map[string]*ClientState),
+		reported: make(map[string]reportedClient),
+		forget:   forget,
+	}
+}
+
+// identify derives a client id from, in order, the jwt id claim, the name the caller reported via
+// engine_getClientVersionV1, the User-Agent product and the remote host.
+func (r *clientRegistry) identify(claims *jwt.Claims, userAgent, remoteAddr string) (id, source string) {
+	if claims != nil {
+		if id := normalizeClientID(claims.ID); id != "" {
+			return id, ClientSourceJWT
+		}
+	}
+
+	host := remoteHost(remoteAddr)
+
+	r.mu.Lock()
+	reported := r.reported[reportedKey(remoteAddr, userAgent)].name
+	r.mu.Unlock()
+
+	if reported != "" {
+		return reported, ClientSourceClientVersion
+	}
+
+	if product := strings.SplitN(userAgent, "/", 2)[0]; product != "" {
+		if id := normalizeClientID(product); id != "" {
+			return id, ClientSourceUserAgent
+		}
+	}
+
+	return host, ClientSourceRemoteAddr
+}
+
+// observe updates the state of the client a request came from. It returns the caller relabelled by the name it
+// reported, if the request reports one.
+func (r *clientRegistry) observe(from caller, source string, body *JSONRequestBody, now time.Time) caller {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state, ok := r.clients[from.client]
+	if !ok {
+		if len(r.clients) >= maxClients {
+			r.forgetClient()
+		}
+
+		state = &ClientState{ID: from.client, Source: source}
+		r.clients[from.client] = state
+	}
+
+	state.LastCall = now
+	state.LastMethod = body.Method
+
+	if len(body.Params) < 1 || body.Params[0] == nil {
+		return from
+	}
+
+	switch {
+	case strings.HasPrefix(body.Method, "engine_forkchoiceUpdated"):
+		var forkchoice exec.RequestParamsForkchoiceUpdatedV1
+		if err := json.Unmarshal(*body.Params[0], &forkchoice); err == nil {
+			state.LastHead = forkchoice.HeadBlockHash
+			state.LastFinalized = forkchoice.FinalizedBlockHash
+			state.LastForkchoiceUpdate = now
+		}
+	case body.Method == "engine_getClientVersionV1":
+		var reported exec.RequestParamsGetClientVersionV1
+		if err := json.Unmarshal(*body.Params[0], &reported); err != nil {
+			return from
+		}
+
+		version := exec.ClientVersionV1(reported)
+		state.Version = &version
+
+		// Identify later requests from this caller by the reported name, unless it identifies itself by jwt.
+		if name := normalizeClientID(version.Name); name != "" && source != ClientSourceJWT {
+			key := reportedKey(from.remoteAddr, from.userAgent)
+			if _, ok := r.reported[key]; !ok && len(r.reported) >= maxClients {
+				r.forgetReported()
+			}
+
+			r.reported[key] = reportedClient{name: name, at: now}
+			from.label = name
+		}
+	}
+
+	return from
+}
+
+// forgetClient removes the least recently seen client.
+func (r *clientRegistry) forgetClient() {
+	var oldest *ClientState
+
+	for _, state := range r.clients {
+		if oldest == nil || state.LastCall.Before(oldest.LastCall) {
+			oldest = state
+		}
+	}
+
+	if oldest == nil {
+		return
+	}
+
+	delete(r.clients, oldest.ID)
+
+	if labelledBySource(oldest.Source) {
+		r.forget(oldest.ID)
+	}
+}
+
+// forgetReported removes the least recently reported client name.
+func (r *clientRegistry) forgetReported() {
+	oldestKey := ""
+
+	var oldest time.Time
+
+	for key, reported := range r.reported {
+		if oldestKey == "" || reported.at.Before(oldest) {
+			oldestKey, oldest = key, reported.at
+		}
+	}
+
+	delete(r.reported, oldestKey)
+}
+
+// list returns a copy of the state of every client seen so far, sorted by id.
+func (r *clientRegistry) list() []ClientState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	clients := make([]ClientState, 0, len(r.clients))
+	for _, state := range r.clients {
+		clients = append(clients, *state)
+	}
+
+	sort.Slice(clients, func(i, j int) bool { return clients[i].ID < clients[j].ID })
+
+	return clients
+}
+
+// reportedKey distinguishes clients sharing a host by their user agent.
+func reportedKey(remoteAddr, userAgent string) string {
+	return remoteHost(remoteAddr) + " " + userAgent
+}
+
+func remoteHost(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+
+	return host
+}
+
+// labelledBySource returns true if clients identified through source are labelled by their id in metrics.
+func labelledBySource(source string) bool {
+	return source == ClientSourceJWT || source == ClientSourceClientVersion
+}
+
+// normalizeClientID turns free form client names into values suitable for metric labels.
+func normalizeClientID(s string) string {
+	return strings.Trim(invalidClientChars.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+// identifyHTTP authenticates r and derives the client id of the caller. Callers failing authentication are
+// all identified as ClientUnauthenticated.
+func (h *Handler) identifyHTTP(r *http.Request) (from caller, source string, err error) {
+	claims, err := h.authenticate(r)
+
+	from.remoteAddr = r.RemoteAddr
+	from.userAgent = r.Header.Get("User-Agent")
+
+	if err != nil {
+		from.client = ClientUnauthenticated
+		from.label = ClientUnauthenticated
+
+		return from, "", err
+	}
+
+	from.client, source = h.clients.identify(claims, from.userAgent, r.RemoteAddr)
+
+	from.label = ClientUnknown
+	if labelledBySource(source) {
+		from.label = from.client
+	}
+
+	return from, source, nil
+}
+
+// Clients returns the state of every consensus client seen so far.
+func (h *Handler) Clients() []ClientState {
+	return h.clients.list()
+}
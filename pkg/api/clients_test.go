@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethpandaops/stubbies/pkg/jwt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func testToken(t *testing.T, id string) string {
+	t.Helper()
+
+	token, err := jwt.NewToken(testSecret, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return "Bearer " + token
+}
+
+func TestIdentifyHTTP(t *testing.T) {
+	h := NewHandler(logrus.New(), nil, prometheus.NewRegistry(), nil, testSecret)
+
+	// 10.0.0.2 reported its name with engine_getClientVersionV1.
+	h.clients.reported[reportedKey("10.0.0.2:1234", "")] = reportedClient{name: "teku", at: time.Now()}
+
+	tests := []struct {
+		name          string
+		authorization string
+		userAgent     string
+		remoteAddr    string
+		client        string
+		label         string
+		source        string
+		err           bool
+	}{
+		{name: "jwt id", authorization: testToken(t, "Lighthouse"), remoteAddr: "10.0.0.1:1234", client: "lighthouse", label: "lighthouse", source: ClientSourceJWT},
+		{name: "reported name", authorization: testToken(t, ""), remoteAddr: "10.0.0.2:1234", client: "teku", label: "teku", source: ClientSourceClientVersion},
+		{name: "user agent", authorization: testToken(t, ""), userAgent: "Prysm/v5.0.0", remoteAddr: "10.0.0.3:1234", client: "prysm", label: ClientUnknown, source: ClientSourceUserAgent},
+		{name: "remote addr", authorization: testToken(t, ""), remoteAddr: "10.0.0.4:1234", client: "10.0.0.4", label: ClientUnknown, source: ClientSourceRemoteAddr},
+		{name: "missing token", userAgent: "Prysm/v5.0.0", remoteAddr: "10.0.0.3:1234", client: ClientUnauthenticated, label: ClientUnauthenticated, err: true},
+		{name: "invalid token", authorization: "Bearer invalid", userAgent: "Prysm/v5.0.0", remoteAddr: "10.0.0.3:1234", client: ClientUnauthenticated, label: ClientUnauthenticated, err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/", nil)
+			r.RemoteAddr = test.remoteAddr
+			r.Header.Set("User-Agent", test.userAgent)
+
+			if test.authorization != "" {
+				r.Header.Set("Authorization", test.authorization)
+			}
+
+			from, source, err := h.identifyHTTP(r)
+			if (err != nil) != test.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if from.client != test.client || from.label != test.label || source != test.source {
+				t.Fatalf("identified as %s (label %s, source %s), expected %s (label %s, source %s)", from.client, from.label, source, test.client, test.label, test.source)
+			}
+		})
+	}
+}
+
+func TestClientRegistryForgetsLeastRecentlySeen(t *testing.T) {
+	metrics := NewMetrics("http", prometheus.NewRegistry())
+	r := newClientRegistry(metrics.DeleteClient)
+	start := time.Now()
+
+	for i := 0; i < maxClients+10; i++ {
+		from := caller{
+			remoteAddr: fmt.Sprintf("10.0.%d.%d:1234", i/256, i%256),
+			client:     fmt.Sprintf("client-%d", i),
+			label:      fmt.Sprintf("client-%d", i),
+		}
+
+		version := json.RawMessage(`{"code":"LH","name":"lighthouse","version":"v5.0.0","commit":"0x00000000"}`)
+		body := &JSONRequestBody{Method: "engine_getClientVersionV1", Params: []*json.RawMessage{&version}}
+		at := start.Add(time.Duration(i) * time.Second)
+
+		r.observe(from, ClientSourceClientVersion, body, at)
+		metrics.ObserveClientCall(from.label, ClientSourceClientVersion, at)
+	}
+
+	if len(r.clients) != maxClients || len(r.reported) != maxClients {
+		t.Fatalf("tracking %d clients and %d reported names, expected %d", len(r.clients), len(r.reported), maxClients)
+	}
+
+	for _, test := range []struct {
+		client  string
+		tracked bool
+	}{
+		{client: "client-0", tracked: false},
+		{client: "client-9", tracked: false},
+		{client: "client-10", tracked: true},
+		{client: fmt.Sprintf("client-%d", maxClients+9), tracked: true},
+	} {
+		if _, ok := r.clients[test.client]; ok != test.tracked {
+			t.Errorf("%s tracked: %v, expected %v", test.client, ok, test.tracked)
+		}
+	}
+
+	if series := testutil.CollectAndCount(metrics.clientLastCall); series != maxClients {
+		t.Fatalf("%d client_last_call_timestamp_seconds series, expected %d", series, maxClients)
+	}
+}
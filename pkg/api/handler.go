@@ -6,10 +6,15 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/ethpandaops/stubbies/pkg/capture"
 	exec "github.com/ethpandaops/stubbies/pkg/execution"
+	"github.com/ethpandaops/stubbies/pkg/jwt"
 	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 )
 
@@ -17,29 +22,87 @@ import (
 type Handler struct {
 	log logrus.FieldLogger
 
-	execution *exec.Handler
+	execution exec.Backend
+	capture   *capture.Writer
+	jwtSecret []byte
 
-	metrics Metrics
+	metrics  Metrics
+	clients  *clientRegistry
+	activity *activityFeed
+
+	done     chan struct{}
+	stopOnce sync.Once
+
+	started int32
 }
 
-func NewHandler(log logrus.FieldLogger, conf *exec.Config) *Handler {
+// NewHandler returns a new Handler instance. captureWriter may be nil to disable traffic capture,
+// jwtSecret may be nil to accept requests without authentication.
+func NewHandler(log logrus.FieldLogger, backend exec.Backend, reg prometheus.Registerer, captureWriter *capture.Writer, jwtSecret []byte) *Handler {
+	metrics := NewMetrics("http", reg)
+
 	return &Handler{
 		log: log.WithField("module", "api"),
 
-		execution: exec.NewHandler(log, conf),
+		execution: backend,
+		capture:   captureWriter,
+		jwtSecret: jwtSecret,
 
-		metrics: NewMetrics("http"),
+		metrics:  metrics,
+		clients:  newClientRegistry(metrics.DeleteClient),
+		activity: newActivityFeed(),
+
+		done: make(chan struct{}),
 	}
 }
 
-func (h *Handler) Register(ctx context.Context, router *httprouter.Router) error {
-	router.POST("/", h.wrappedHandler(h.handleExecution))
+// Register registers the handler's routes below pathPrefix, e.g. "/tenant/a". An empty prefix registers at the root.
+func (h *Handler) Register(ctx context.Context, router *httprouter.Router, pathPrefix string) error {
+	pathPrefix = strings.TrimSuffix(pathPrefix, "/")
+
+	router.POST(pathPrefix+"/", h.wrappedHandler(h.handleExecution))
+	router.GET(pathPrefix+"/", h.handleWebSocket)
+
+	if pathPrefix != "" {
+		router.POST(pathPrefix, h.wrappedHandler(h.handleExecution))
+		router.GET(pathPrefix, h.handleWebSocket)
+	}
 
 	return nil
 }
 
-func (h *Handler) Start(ctx context.Context) {
-	h.execution.Start(ctx)
+func (h *Handler) Start(ctx context.Context) error {
+	if err := h.execution.Start(ctx); err != nil {
+		return err
+	}
+
+	atomic.StoreInt32(&h.started, 1)
+
+	return nil
+}
+
+// Started returns true once the backend (and its storage) has been started.
+func (h *Handler) Started() bool {
+	return atomic.LoadInt32(&h.started) == 1
+}
+
+// LastEngineCall returns when the last engine_* request was received, or the zero time if none has been.
+func (h *Handler) LastEngineCall() time.Time {
+	return h.execution.LastEngineCall()
+}
+
+// Stop closes open websocket and ipc connections and activity subscriptions, and stops the backend.
+func (h *Handler) Stop(ctx context.Context) error {
+	var err error
+
+	h.stopOnce.Do(func() {
+		close(h.done)
+		h.activity.Close()
+
+		err = h.execution.Stop(ctx)
+	})
+
+	return err
 }
 
 func deriveRegisteredPath(request *http.Request, ps httprouter.Params) string {
@@ -51,7 +114,7 @@ func deriveRegisteredPath(request *http.Request, ps httprouter.Params) string {
 	return registeredPath
 }
 
-func (h *Handler) wrappedHandler(handler func(ctx context.Context, r *http.Request, p httprouter.Params, contentType ContentType, body *JSONRequestBody) (*HTTPResponse, error)) httprouter.Handle {
+func (h *Handler) wrappedHandler(handler func(ctx context.Context, from caller, contentType ContentType, body *JSONRequestBody) (*HTTPResponse, error)) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		start := time.Now()
 
@@ -68,16 +131,27 @@ func (h *Handler) wrappedHandler(handler func(ctx context.Context, r *http.Reque
 
 		executionMethod := "unknown"
 
-		h.metrics.ObserveRequest(r.Method, registeredPath, executionMethod)
+		from, source, authErr := h.identifyHTTP(r)
+
+		h.metrics.ObserveRequest(r.Method, registeredPath, executionMethod, from.label)
 
 		responseStatusCode := http.StatusInternalServerError
 
 		var err error
 
 		defer func() {
-			h.metrics.ObserveResponse(r.Method, registeredPath, fmt.Sprintf("%v", responseStatusCode), contentType.String(), executionMethod, time.Since(start))
+			h.metrics.ObserveResponse(r.Method, registeredPath, fmt.Sprintf("%v", responseStatusCode), contentType.String(), executionMethod, from.label, time.Since(start))
 		}()
 
+		if err = authErr; err != nil {
+			responseStatusCode = http.StatusUnauthorized
+			if writeErr := WriteErrorResponse(w, err.Error(), responseStatusCode); writeErr != nil {
+				h.log.WithError(writeErr).Error("Failed to write unauthorized response")
+			}
+
+			return
+		}
+
 		decoder := json.NewDecoder(r.Body)
 
 		var body JSONRequestBody
@@ -103,7 +177,7 @@ func (h *Handler) wrappedHandler(handler func(ctx context.Context, r *http.Reque
 
 		executionMethod = body.Method
 
-		response, err := handler(ctx, r, p, contentType, &body)
+		response, err := handler(ctx, h.observeClient(from, source, &body), contentType, &body)
 		if err != nil {
 			if response != nil && response.StatusCode != 0 {
 				responseStatusCode = response.StatusCode
@@ -138,11 +212,59 @@ func (h *Handler) wrappedHandler(handler func(ctx context.Context, r *http.Reque
 	}
 }
 
-func (h *Handler) handleExecution(ctx context.Context, r *http.Request, p httprouter.Params, contentType ContentType, body *JSONRequestBody) (*HTTPResponse, error) {
+// authenticate verifies the jwt of r, returning its claims. Without a jwt secret every request is accepted
+// with empty claims.
+func (h *Handler) authenticate(r *http.Request) (*jwt.Claims, error) {
+	if h.jwtSecret == nil {
+		return &jwt.Claims{}, nil
+	}
+
+	token, err := jwt.FromAuthorizationHeader(r.Header.Get("Authorization"))
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := jwt.Verify(token, h.jwtSecret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+
+	return claims, nil
+}
+
+// observeClient records a request in the state of the client it came from, returning the caller to execute the
+// request as.
+func (h *Handler) observeClient(from caller, source string, body *JSONRequestBody) caller {
+	now := time.Now()
+
+	h.metrics.ObserveClientCall(from.label, source, now)
+
+	return h.clients.observe(from, source, body, now)
+}
+
+func (h *Handler) handleExecution(ctx context.Context, from caller, contentType ContentType, body *JSONRequestBody) (*HTTPResponse, error) {
 	if err := ValidateContentType(contentType, []ContentType{ContentTypeJSON}); err != nil {
 		return NewUnsupportedMediaTypeResponse(nil), err
 	}
 
+	resp, err := h.execute(ctx, from, body)
+	if err != nil {
+		return NewInternalServerErrorResponse(nil), err
+	}
+
+	var rsp = NewSuccessResponse(ContentTypeResolvers{
+		ContentTypeJSON: func() ([]byte, error) {
+			return json.Marshal(resp)
+		},
+	})
+
+	rsp.SetCacheControl("public, s-max-age=30")
+
+	return rsp, nil
+}
+
+// execute dispatches a single JSON-RPC request to the backend, regardless of the transport it arrived on.
+func (h *Handler) execute(ctx context.Context, from caller, body *JSONRequestBody) (*exec.Response, error) {
 	var parms []string
 
 	for _, param := range body.Params {
@@ -155,20 +277,49 @@ func (h *Handler) handleExecution(ctx context.Context, r *http.Request, p httpro
 		"method": body.Method,
 		"params": parms,
 		"id":     body.ID,
+		"client": from.client,
 	}).Debug("handling execution request")
 
-	resp, err := h.execution.Request(ctx, body.ID, body.Method, body.Params)
+	start := time.Now()
+
+	resp, err := h.execution.Request(exec.WithClient(ctx, from.label), body.ID, body.Method, body.Params)
+
+	if h.capture != nil {
+		h.captureExchange(start, from, body, resp, err)
+	}
+
+	h.activity.Publish(newActivity(start, from, body, resp, err))
+
+	return resp, err
+}
+
+func (h *Handler) captureExchange(start time.Time, from caller, body *JSONRequestBody, resp *exec.Response, respErr error) {
+	entry := capture.NewEntry(start, from.remoteAddr, body.Method)
+	entry.Client = from.client
+
+	request, err := json.Marshal(body)
 	if err != nil {
-		return NewInternalServerErrorResponse(nil), err
+		h.log.WithError(err).Error("Failed to marshal request for capture")
+
+		return
 	}
 
-	var rsp = NewSuccessResponse(ContentTypeResolvers{
-		ContentTypeJSON: func() ([]byte, error) {
-			return json.Marshal(resp)
-		},
-	})
+	entry.Request = request
 
-	rsp.SetCacheControl("public, s-max-age=30")
+	if respErr != nil {
+		entry.Error = respErr.Error()
+	} else {
+		response, err := json.Marshal(resp)
+		if err != nil {
+			h.log.WithError(err).Error("Failed to marshal response for capture")
 
-	return rsp, nil
+			return
+		}
+
+		entry.Response = response
+	}
+
+	if err := h.capture.Write(entry); err != nil {
+		h.log.WithError(err).Error("Failed to write capture entry")
+	}
 }
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+)
+
+// ServeIPC serves JSON-RPC on a stream connection, e.g. a unix socket, using the same framing as geth:
+// a stream of JSON values in, newline delimited JSON values out. Batches are supported.
+func (h *Handler) ServeIPC(ctx context.Context, conn net.Conn, path string) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	encoder := json.NewEncoder(conn)
+
+	c := newStreamConn(h.log.WithField("ipc", path), encoder.Encode)
+
+	// Unix sockets carry neither a jwt nor a meaningful remote address.
+	from := caller{remoteAddr: "ipc", client: "ipc", label: "ipc"}
+
+	defer func() {
+		c.close()
+		conn.Close()
+	}()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-h.done:
+		}
+
+		conn.Close()
+	}()
+
+	decoder := json.NewDecoder(bufio.NewReader(conn))
+
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if err != io.EOF && ctx.Err() == nil {
+				h.log.WithError(err).Debug("Failed to read ipc message")
+			}
+
+			return
+		}
+
+		raw = bytes.TrimSpace(raw)
+
+		if len(raw) > 0 && raw[0] == '[' {
+			var batch []JSONRequestBody
+			if err := json.Unmarshal(raw, &batch); err != nil {
+				c.writeJSON(errorResponse(0, err))
+
+				continue
+			}
+
+			responses := make([]interface{}, 0, len(batch))
+			for i := range batch {
+				responses = append(responses, h.observeStreamRequest(ctx, c, "IPC", path, from, ClientSourceRemoteAddr, &batch[i]))
+			}
+
+			c.writeJSON(responses)
+
+			continue
+		}
+
+		var body JSONRequestBody
+		if err := json.Unmarshal(raw, &body); err != nil {
+			c.writeJSON(errorResponse(0, err))
+
+			continue
+		}
+
+		c.writeJSON(h.observeStreamRequest(ctx, c, "IPC", path, from, ClientSourceRemoteAddr, &body))
+	}
+}
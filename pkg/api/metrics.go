@@ -10,40 +10,61 @@ type Metrics struct {
 	requests        *prometheus.CounterVec
 	responses       *prometheus.CounterVec
 	requestDuration *prometheus.HistogramVec
+	clientLastCall  *prometheus.GaugeVec
 }
 
-func NewMetrics(namespace string) Metrics {
+func NewMetrics(namespace string, reg prometheus.Registerer) Metrics {
 	m := Metrics{
 		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Namespace: namespace,
 			Name:      "request_count",
 			Help:      "Number of requests",
-		}, []string{"method", "path", "execution_method"}),
+		}, []string{"method", "path", "execution_method", "client"}),
 		responses: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Namespace: namespace,
 			Name:      "response_count",
 			Help:      "Number of responses",
-		}, []string{"method", "path", "code", "encoding", "execution_method"}),
+		}, []string{"method", "path", "code", "encoding", "execution_method", "client"}),
 		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
 			Namespace: namespace,
 			Name:      "request_duration_seconds",
 			Help:      "Request duration (in seconds.)",
 			Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
-		}, []string{"method", "path", "encoding", "execution_method"}),
+		}, []string{"method", "path", "encoding", "execution_method", "client"}),
+		clientLastCall: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "client_last_call_timestamp_seconds",
+			Help:      "Time of the last request of each consensus client",
+		}, []string{"client", "source"}),
 	}
 
-	prometheus.MustRegister(m.requests)
-	prometheus.MustRegister(m.responses)
-	prometheus.MustRegister(m.requestDuration)
+	reg.MustRegister(m.requests)
+	reg.MustRegister(m.responses)
+	reg.MustRegister(m.requestDuration)
+	reg.MustRegister(m.clientLastCall)
 
 	return m
 }
 
-func (m Metrics) ObserveRequest(method, path, executionMethod string) {
-	m.requests.WithLabelValues(method, path, executionMethod).Inc()
+func (m Metrics) ObserveRequest(method, path, executionMethod, client string) {
+	m.requests.WithLabelValues(method, path, executionMethod, client).Inc()
+}
+
+func (m Metrics) ObserveResponse(method, path, code, encoding, executionMethod, client string, duration time.Duration) {
+	m.responses.WithLabelValues(method, path, code, encoding, executionMethod, client).Inc()
+	m.requestDuration.WithLabelValues(method, path, encoding, executionMethod, client).Observe(duration.Seconds())
 }
 
-func (m Metrics) ObserveResponse(method, path, code, encoding, executionMethod string, duration time.Duration) {
-	m.responses.WithLabelValues(method, path, code, encoding, executionMethod).Inc()
-	m.requestDuration.WithLabelValues(method, path, encoding, executionMethod).Observe(duration.Seconds())
+func (m Metrics) ObserveClientCall(client, source string, at time.Time) {
+	m.clientLastCall.WithLabelValues(client, source).Set(float64(at.Unix()))
+}
+
+// DeleteClient removes the series of a client that is no longer tracked.
+func (m Metrics) DeleteClient(client string) {
+	labels := prometheus.Labels{"client": client}
+
+	m.requests.DeletePartialMatch(labels)
+	m.responses.DeletePartialMatch(labels)
+	m.requestDuration.DeletePartialMatch(labels)
+	m.clientLastCall.DeletePartialMatch(labels)
 }
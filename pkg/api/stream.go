@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	exec "github.com/ethpandaops/stubbies/pkg/execution"
+	"github.com/sirupsen/logrus"
+)
+
+type subscriptionNotification struct {
+	JSONRPC string             `json:"jsonrpc"`
+	Method  string             `json:"method"`
+	Params  subscriptionResult `json:"params"`
+}
+
+type subscriptionResult struct {
+	Subscription string      `json:"subscription"`
+	Result       interface{} `json:"result"`
+}
+
+// streamConn is a persistent connection (websocket or ipc) that supports subscriptions.
+// Responses and subscription notifications are written concurrently.
+type streamConn struct {
+	log   logrus.FieldLogger
+	write func(v interface{}) error
+
+	subscriptions map[string]func()
+
+	mu sync.Mutex
+}
+
+func newStreamConn(log logrus.FieldLogger, write func(v interface{}) error) *streamConn {
+	return &streamConn{
+		log:           log,
+		write:         write,
+		subscriptions: make(map[string]func()),
+	}
+}
+
+func (c *streamConn) writeJSON(v interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.write(v); err != nil {
+		c.log.WithError(err).Debug("Failed to write message")
+	}
+}
+
+func (c *streamConn) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, unsubscribe := range c.subscriptions {
+		unsubscribe()
+		delete(c.subscriptions, id)
+	}
+}
+
+func (h *Handler) handleStreamRequest(ctx context.Context, c *streamConn, from caller, body *JSONRequestBody) (*exec.Response, error) {
+	switch body.Method {
+	case "":
+		return nil, errors.New("missing method")
+	case "eth_subscribe":
+		return h.subscribe(ctx, c, body)
+	case "eth_unsubscribe":
+		return h.unsubscribe(c, body)
+	}
+
+	return h.execute(ctx, from, body)
+}
+
+func (h *Handler) subscribe(ctx context.Context, c *streamConn, body *JSONRequestBody) (*exec.Response, error) {
+	if len(body.Params) < 1 || body.Params[0] == nil {
+		return nil, errors.New("missing params")
+	}
+
+	var kind string
+	if err := json.Unmarshal(*body.Params[0], &kind); err != nil {
+		return nil, err
+	}
+
+	if kind != "newHeads" {
+		return nil, fmt.Errorf("unsupported subscription: %s", kind)
+	}
+
+	id, err := newSubscriptionID()
+	if err != nil {
+		return nil, err
+	}
+
+	heads, unsubscribe := h.execution.SubscribeNewHeads()
+
+	c.mu.Lock()
+	c.subscriptions[id] = unsubscribe
+	c.mu.Unlock()
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case header, ok := <-heads:
+				if !ok {
+					return
+				}
+
+				c.writeJSON(subscriptionNotification{
+					JSONRPC: "2.0",
+					Method:  "eth_subscription",
+					Params: subscriptionResult{
+						Subscription: id,
+						Result:       header,
+					},
+				})
+			}
+		}
+	}()
+
+	return &exec.Response{ID: body.ID, JSONRPC: "2.0", Result: id}, nil
+}
+
+func (h *Handler) unsubscribe(c *streamConn, body *JSONRequestBody) (*exec.Response, error) {
+	if len(body.Params) < 1 || body.Params[0] == nil {
+		return nil, errors.New("missing params")
+	}
+
+	var id string
+	if err := json.Unmarshal(*body.Params[0], &id); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	unsubscribe, exists := c.subscriptions[id]
+	delete(c.subscriptions, id)
+	c.mu.Unlock()
+
+	if exists {
+		unsubscribe()
+	}
+
+	return &exec.Response{ID: body.ID, JSONRPC: "2.0", Result: exists}, nil
+}
+
+func errorResponse(id int, err error) *exec.Response {
+	var rpcErr *exec.ResponseError
+	if errors.As(err, &rpcErr) {
+		return &exec.Response{ID: id, JSONRPC: "2.0", Error: rpcErr}
+	}
+
+	return &exec.Response{
+		ID:      id,
+		JSONRPC: "2.0",
+		Error: &exec.ResponseError{
+			Code:    exec.ErrorCodeServerError,
+			Message: err.Error(),
+		},
+	}
+}
+
+func newSubscriptionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return "0x" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/julienschmidt/httprouter"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	wsReadLimit    = 32 * 1024 * 1024
+	wsWriteTimeout = 10 * time.Second
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+	// Engine api clients are authenticated with jwt rather than by origin.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+func (h *Handler) handleWebSocket(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	registeredPath := deriveRegisteredPath(r, p)
+
+	from, source, err := h.identifyHTTP(r)
+	if err != nil {
+		h.metrics.ObserveResponse(r.Method, registeredPath, fmt.Sprintf("%v", http.StatusUnauthorized), ContentTypeJSON.String(), "websocket", from.label, 0)
+
+		if writeErr := WriteErrorResponse(w, err.Error(), http.StatusUnauthorized); writeErr != nil {
+			h.log.WithError(writeErr).Error("Failed to write unauthorized response")
+		}
+
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		h.log.WithError(err).Debug("Failed to upgrade websocket connection")
+
+		return
+	}
+
+	conn.SetReadLimit(wsReadLimit)
+
+	c := newStreamConn(h.log.WithFields(logrus.Fields{"remote_addr": r.RemoteAddr, "client": from.client}), func(v interface{}) error {
+		if err := conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout)); err != nil {
+			return err
+		}
+
+		return conn.WriteJSON(v)
+	})
+
+	c.log.Debug("websocket connection opened")
+
+	defer func() {
+		c.close()
+		conn.Close()
+		c.log.Debug("websocket connection closed")
+	}()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// Hijacked connections are not closed by http.Server.Shutdown.
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-h.done:
+			conn.Close()
+		}
+	}()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var body JSONRequestBody
+		if err := json.Unmarshal(data, &body); err != nil {
+			c.writeJSON(errorResponse(0, err))
+
+			continue
+		}
+
+		c.writeJSON(h.observeStreamRequest(ctx, c, "WS", registeredPath, from, source, &body))
+	}
+}
+
+// observeStreamRequest handles a request received on a persistent connection, recording metrics as the http handler does.
+func (h *Handler) observeStreamRequest(ctx context.Context, c *streamConn, transport, path string, from caller, source string, body *JSONRequestBody) interface{} {
+	start := time.Now()
+
+	h.metrics.ObserveRequest(transport, path, body.Method, from.label)
+
+	identified := from
+	if body.Method != "" {
+		identified = h.observeClient(from, source, body)
+	}
+
+	code := http.StatusOK
+
+	resp, err := h.handleStreamRequest(ctx, c, identified, body)
+	if err != nil {
+		code = http.StatusInternalServerError
+		resp = errorResponse(body.ID, err)
+	}
+
+	h.metrics.ObserveResponse(transport, path, fmt.Sprintf("%v", code), ContentTypeJSON.String(), body.Method, from.label, time.Since(start))
+
+	return resp
+}
@@ -0,0 +1,32 @@
+package capture
+
+import "errors"
+
+type Config struct {
+	Enabled bool   `yaml:"enabled" default:"false"`
+	Path    string `yaml:"path" default:"capture.jsonl"`
+	// MaxSizeMB is the size at which the capture file is rotated. 0 disables rotation.
+	MaxSizeMB int `yaml:"maxSizeMb" default:"100"`
+	// MaxFiles is the number of rotated files to keep alongside the active one.
+	MaxFiles int `yaml:"maxFiles" default:"5"`
+}
+
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.Path == "" {
+		return errors.New("capture.path is required")
+	}
+
+	if c.MaxSizeMB < 0 {
+		return errors.New("capture.maxSizeMb must be positive")
+	}
+
+	if c.MaxFiles < 0 {
+		return errors.New("capture.maxFiles must be positive")
+	}
+
+	return nil
+}
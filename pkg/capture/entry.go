@@ -0,0 +1,32 @@
+package capture
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// FormatVersion is bumped whenever the on disk format of Entry changes in a breaking way.
+const FormatVersion = 1
+
+// Entry is a single request/response pair. Each line of a capture file is one JSON encoded Entry.
+type Entry struct {
+	Version    int             `json:"version"`
+	Time       time.Time       `json:"time"`
+	LatencyMS  float64         `json:"latencyMs"`
+	RemoteAddr string          `json:"remoteAddr"`
+	Client     string          `json:"client,omitempty"`
+	Method     string          `json:"method"`
+	Request    json.RawMessage `json:"request"`
+	Response   json.RawMessage `json:"response,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+func NewEntry(start time.Time, remoteAddr, method string) *Entry {
+	return &Entry{
+		Version:    FormatVersion,
+		Time:       start.UTC(),
+		LatencyMS:  float64(time.Since(start).Microseconds()) / 1000,
+		RemoteAddr: remoteAddr,
+		Method:     method,
+	}
+}
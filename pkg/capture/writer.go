@@ -0,0 +1,168 @@
+package capture
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Writer appends entries to a JSONL capture file, rotating it once it grows past the configured size.
+type Writer struct {
+	log logrus.FieldLogger
+	cfg Config
+
+	file *os.File
+	size int64
+	// closed is set by Close, file is nil without closed when reopening it failed.
+	closed bool
+	// failing is set while rotating or reopening the file fails, to log the failure once.
+	failing bool
+
+	mu sync.Mutex
+}
+
+func NewWriter(log logrus.FieldLogger, conf *Config) (*Writer, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	w := &Writer{
+		log: log.WithField("module", "capture"),
+		cfg: *conf,
+	}
+
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	w.log.WithField("path", conf.Path).Info("capturing engine api traffic")
+
+	return w, nil
+}
+
+func (w *Writer) Write(entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	data = append(data, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return os.ErrClosed
+	}
+
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return w.fail(err)
+		}
+
+		w.failing = false
+	}
+
+	if w.cfg.MaxSizeMB > 0 && w.size+int64(len(data)) > int64(w.cfg.MaxSizeMB)*1024*1024 {
+		if err := w.rotate(); err != nil {
+			// Keep appending to the current file, rotation is retried on the next write.
+			if w.file == nil {
+				if openErr := w.open(); openErr != nil {
+					return w.fail(openErr)
+				}
+			}
+
+			_ = w.fail(err)
+		} else {
+			w.failing = false
+		}
+	}
+
+	n, err := w.file.Write(data)
+	w.size += int64(n)
+
+	return err
+}
+
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.closed = true
+
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.file.Close()
+	w.file = nil
+
+	return err
+}
+
+// fail logs err unless the previous write failed too, and returns it.
+func (w *Writer) fail(err error) error {
+	if !w.failing {
+		w.log.WithError(err).WithField("path", w.cfg.Path).Error("Failed to rotate or reopen capture file")
+	}
+
+	w.failing = true
+
+	return err
+}
+
+func (w *Writer) open() error {
+	file, err := os.OpenFile(w.cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+
+		return err
+	}
+
+	w.file = file
+	w.size = info.Size()
+
+	return nil
+}
+
+func (w *Writer) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+
+	w.file = nil
+
+	if w.cfg.MaxFiles == 0 {
+		if err := os.Remove(w.cfg.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		// capture.jsonl.4 -> capture.jsonl.5, ..., capture.jsonl -> capture.jsonl.1
+		for i := w.cfg.MaxFiles - 1; i >= 0; i-- {
+			from := w.rotatedPath(i)
+			if err := os.Rename(from, w.rotatedPath(i+1)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	w.log.WithField("path", w.cfg.Path).Debug("rotated capture file")
+
+	return w.open()
+}
+
+func (w *Writer) rotatedPath(i int) string {
+	if i == 0 {
+		return w.cfg.Path
+	}
+
+	return fmt.Sprintf("%s.%d", w.cfg.Path, i)
+}
@@ -0,0 +1,84 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// entryOfSize returns an entry encoding to roughly size bytes.
+func entryOfSize(size int) *Entry {
+	entry := NewEntry(time.Now(), "127.0.0.1:1234", "engine_newPayloadV1")
+	entry.Request = json.RawMessage(`"` + string(bytes.Repeat([]byte("a"), size)) + `"`)
+
+	return entry
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return bytes.Count(data, []byte("\n"))
+}
+
+func TestWriterRotate(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxFiles int
+		// rotatedDir makes the rotated file a non-empty directory, so rotation fails.
+		rotatedDir bool
+		lines      map[string]int
+	}{
+		{name: "keeps rotated files", maxFiles: 2, lines: map[string]int{"capture.jsonl": 1, "capture.jsonl.1": 1, "capture.jsonl.2": 1}},
+		{name: "drops the file without rotated files", maxFiles: 0, lines: map[string]int{"capture.jsonl": 1}},
+		{name: "appends when rotation fails", maxFiles: 1, rotatedDir: true, lines: map[string]int{"capture.jsonl": 4}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "capture.jsonl")
+
+			if test.rotatedDir {
+				if err := os.MkdirAll(filepath.Join(path+".1", "blocker"), 0o700); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			w, err := NewWriter(logrus.New(), &Config{Enabled: true, Path: path, MaxSizeMB: 1, MaxFiles: test.maxFiles})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Every entry fills most of the 1MB, so each write after the first rotates.
+			for i := 0; i < 4; i++ {
+				if err := w.Write(entryOfSize(700 * 1024)); err != nil {
+					t.Fatalf("write %d failed: %v", i, err)
+				}
+			}
+
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			for name, lines := range test.lines {
+				if got := countLines(t, filepath.Join(dir, name)); got != lines {
+					t.Errorf("%s has %d entries, expected %d", name, got, lines)
+				}
+			}
+
+			if err := w.Write(entryOfSize(1)); err != os.ErrClosed {
+				t.Fatalf("write after close returned %v, expected %v", err, os.ErrClosed)
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+// Package client talks to a running stubbies: its engine api and the endpoints served on its metrics addr.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethpandaops/stubbies/pkg/api"
+	exec "github.com/ethpandaops/stubbies/pkg/execution"
+	"github.com/ethpandaops/stubbies/pkg/health"
+	"github.com/ethpandaops/stubbies/pkg/rpc"
+)
+
+// DefaultTimeout of requests, except the /events stream.
+const DefaultTimeout = 12 * time.Second
+
+// Config configures a Client.
+type Config struct {
+	// EngineURL is the engine api, e.g. "http://127.0.0.1:8551".
+	EngineURL string
+	// MetricsURL serves the health checks, /clients and /events, e.g. "http://127.0.0.1:9090".
+	MetricsURL string
+	// JWTSecret signs engine api requests, it may be nil when stubbies doesn't authenticate.
+	JWTSecret []byte
+	// Timeout of requests, defaults to DefaultTimeout.
+	Timeout time.Duration
+}
+
+func (c *Config) Validate() error {
+	if c.EngineURL == "" && c.MetricsURL == "" {
+		return errors.New("one of engineUrl and metricsUrl is required")
+	}
+
+	return nil
+}
+
+// Client calls the engine api with the request and result types of the execution package, and the
+// admin endpoints of stubbies.
+type Client struct {
+	cfg Config
+
+	rpc  *rpc.Client
+	http *http.Client
+}
+
+// New returns a new Client.
+func New(conf *Config) (*Client, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	timeout := conf.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	return &Client{
+		cfg: *conf,
+		rpc: rpc.NewClient(conf.EngineURL, conf.JWTSecret, timeout),
+		http: &http.Client{
+			Timeout: timeout,
+		},
+	}, nil
+}
+
+// RPC returns the underlying JSON-RPC client, for methods without a typed wrapper.
+func (c *Client) RPC() *rpc.Client {
+	return c.rpc
+}
+
+// Call sends a JSON-RPC request and decodes the result into result. JSON-RPC errors are returned as
+// *execution.ResponseError.
+func (c *Client) Call(ctx context.Context, method string, result interface{}, params ...interface{}) error {
+	if c.cfg.EngineURL == "" {
+		return errors.New("no engine url configured")
+	}
+
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	return c.rpc.Call(ctx, method, params, result)
+}
+
+// NewPayloadV1 sends engine_newPayloadV1.
+func (c *Client) NewPayloadV1(ctx context.Context, payload *exec.RequestParamsNewPayloadV1) (*exec.ResultNewPayloadV1, error) {
+	return c.newPayload(ctx, "engine_newPayloadV1", payload)
+}
+
+// NewPayloadV2 sends engine_newPayloadV2. payload is typically an *execution.RequestParamsNewPayloadV1, or a
+// struct embedding it with the fields added by later forks.
+func (c *Client) NewPayloadV2(ctx context.Context, payload interface{}) (*exec.ResultNewPayloadV1, error) {
+	return c.newPayload(ctx, "engine_newPayloadV2", payload)
+}
+
+// NewPayloadV3 sends engine_newPayloadV3.
+func (c *Client) NewPayloadV3(ctx context.Context, payload interface{}, versionedHashes []string, parentBeaconBlockRoot string) (*exec.ResultNewPayloadV1, error) {
+	if versionedHashes == nil {
+		versionedHashes = []string{}
+	}
+
+	return c.newPayload(ctx, "engine_newPayloadV3", payload, versionedHashes, parentBeaconBlockRoot)
+}
+
+func (c *Client) newPayload(ctx context.Context, method string, params ...interface{}) (*exec.ResultNewPayloadV1, error) {
+	result := &exec.ResultNewPayloadV1{}
+	if err := c.Call(ctx, method, result, params...); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ForkchoiceUpdatedV1 sends engine_forkchoiceUpdatedV1. attributes may be nil.
+func (c *Client) ForkchoiceUpdatedV1(ctx context.Context, state *exec.RequestParamsForkchoiceUpdatedV1, attributes *exec.RequestParamsPayloadAttributes) (*exec.ResultForkchoiceUpdatedV1, error) {
+	return c.forkchoiceUpdated(ctx, "engine_forkchoiceUpdatedV1", state, attributes)
+}
+
+// ForkchoiceUpdatedV2 sends engine_forkchoiceUpdatedV2. attributes may be nil.
+func (c *Client) ForkchoiceUpdatedV2(ctx context.Context, state *exec.RequestParamsForkchoiceUpdatedV1, attributes *exec.RequestParamsPayloadAttributes) (*exec.ResultForkchoiceUpdatedV1, error) {
+	return c.forkchoiceUpdated(ctx, "engine_forkchoiceUpdatedV2", state, attributes)
+}
+
+func (c *Client) forkchoiceUpdated(ctx context.Context, method string, state *exec.RequestParamsForkchoiceUpdatedV1, attributes *exec.RequestParamsPayloadAttributes) (*exec.ResultForkchoiceUpdatedV1, error) {
+	result := &exec.ResultForkchoiceUpdatedV1{}
+	if err := c.Call(ctx, method, result, state, attributes); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ExchangeCapabilities sends engine_exchangeCapabilities, returning the methods supported by stubbies.
+func (c *Client) ExchangeCapabilities(ctx context.Context, capabilities []string) ([]string, error) {
+	var result exec.ResultexchangeCapabilities
+	if err := c.Call(ctx, "engine_exchangeCapabilities", &result, exec.RequestParamsExchangeCapabilities(capabilities)); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ExchangeTransitionConfigurationV1 sends engine_exchangeTransitionConfigurationV1.
+func (c *Client) ExchangeTransitionConfigurationV1(ctx context.Context, conf *exec.ResultExchangeTransitionConfigurationV1) (*exec.ResultExchangeTransitionConfigurationV1, error) {
+	result := &exec.ResultExchangeTransitionConfigurationV1{}
+	if err := c.Call(ctx, "engine_exchangeTransitionConfigurationV1", result, conf); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetClientVersionV1 reports version to stubbies with engine_getClientVersionV1, returning the identity of stubbies.
+func (c *Client) GetClientVersionV1(ctx context.Context, version *exec.ClientVersionV1) ([]exec.ClientVersionV1, error) {
+	var result exec.ResultGetClientVersionV1
+	if err := c.Call(ctx, "engine_getClientVersionV1", &result, version); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ChainID sends eth_chainId.
+func (c *Client) ChainID(ctx context.Context) (string, error) {
+	var result exec.ResultChainID
+	if err := c.Call(ctx, "eth_chainId", &result); err != nil {
+		return "", err
+	}
+
+	return string(result), nil
+}
+
+// BlockNumber sends eth_blockNumber.
+func (c *Client) BlockNumber(ctx context.Context) (string, error) {
+	var result string
+	if err := c.Call(ctx, "eth_blockNumber", &result); err != nil {
+		return "", err
+	}
+
+	return result, nil
+}
+
+// GetBlockByHash sends eth_getBlockByHash. ok is false when stubbies doesn't know the block.
+func (c *Client) GetBlockByHash(ctx context.Context, hash string) (block *exec.ResultGetBlock, ok bool, err error) {
+	return c.getBlock(ctx, "eth_getBlockByHash", hash)
+}
+
+// GetBlockByNumber sends eth_getBlockByNumber, number is hex encoded or "latest". ok is false when stubbies
+// doesn't know the block.
+func (c *Client) GetBlockByNumber(ctx context.Context, number string) (block *exec.ResultGetBlock, ok bool, err error) {
+	return c.getBlock(ctx, "eth_getBlockByNumber", number)
+}
+
+func (c *Client) getBlock(ctx context.Context, method, query string) (*exec.ResultGetBlock, bool, error) {
+	var raw json.RawMessage
+	if err := c.Call(ctx, method, &raw, query, false); err != nil {
+		return nil, false, err
+	}
+
+	// Unknown blocks are answered with null, or an empty object encoded as a string.
+	if len(raw) == 0 || raw[0] != '{' {
+		return nil, false, nil
+	}
+
+	var block exec.ResultGetBlock
+	if err := json.Unmarshal(raw, &block); err != nil {
+		return nil, false, err
+	}
+
+	return &block, block.Hash != "", nil
+}
+
+// Health returns the /healthz checks. An unhealthy stubbies is not an error, see the status of the response.
+func (c *Client) Health(ctx context.Context) (*health.Response, error) {
+	return c.health(ctx, "/healthz")
+}
+
+// Ready returns the /readyz checks.
+func (c *Client) Ready(ctx context.Context) (*health.Response, error) {
+	return c.health(ctx, "/readyz")
+}
+
+// Live returns the /livez checks.
+func (c *Client) Live(ctx context.Context) (*health.Response, error) {
+	return c.health(ctx, "/livez")
+}
+
+func (c *Client) health(ctx context.Context, path string) (*health.Response, error) {
+	result := &health.Response{}
+	if err := c.get(ctx, path, result, http.StatusOK, http.StatusServiceUnavailable); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Clients returns the consensus clients seen by each tenant.
+func (c *Client) Clients(ctx context.Context) (map[string][]api.ClientState, error) {
+	var result map[string][]api.ClientState
+	if err := c.get(ctx, "/clients", &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (c *Client) metricsRequest(ctx context.Context, path string) (*http.Request, error) {
+	if c.cfg.MetricsURL == "" {
+		return nil, errors.New("no metrics url configured")
+	}
+
+	return http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(c.cfg.MetricsURL, "/")+path, nil)
+}
+
+func (c *Client) get(ctx context.Context, path string, result interface{}, statusCodes ...int) error {
+	req, err := c.metricsRequest(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	rsp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	data, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return err
+	}
+
+	for _, code := range statusCodes {
+		if rsp.StatusCode == code {
+			return json.Unmarshal(data, result)
+		}
+	}
+
+	return fmt.Errorf("%s returned %s: %s", path, rsp.Status, strings.TrimSpace(string(data)))
+}
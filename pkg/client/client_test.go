@@ -0,0 +1,388 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethpandaops/stubbies/pkg/api"
+	exec "github.com/ethpandaops/stubbies/pkg/execution"
+	"github.com/ethpandaops/stubbies/pkg/health"
+	"github.com/ethpandaops/stubbies/pkg/jwt"
+	"github.com/ethpandaops/stubbies/pkg/stubbiestest"
+)
+
+const testSecret = "0x3031323334353637383961626364656630313233343536373839616263646566"
+
+// newTestClient starts stubbies requiring jwt authentication, returning a client authenticating with secret.
+func newTestClient(t *testing.T, secret string) *Client {
+	t.Helper()
+
+	conf, err := stubbiestest.DefaultConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf.JWTSecret = testSecret
+	conf.Execution.ChainID = "0x539"
+	conf.Execution.UnknownMethods = exec.UnknownMethodsError
+
+	stubbies, err := stubbiestest.New(nil, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = stubbies.Close() })
+
+	clientConf := &Config{EngineURL: stubbies.URL, MetricsURL: stubbies.MetricsURL, Timeout: 5 * time.Second}
+
+	if secret != "" {
+		clientConf.JWTSecret, err = jwt.ParseSecret(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := New(clientConf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func testPayload(number, hash, parent string) *exec.RequestParamsNewPayloadV1 {
+	return &exec.RequestParamsNewPayloadV1{
+		BlockNumber:   number,
+		Timestamp:     "0x3f4",
+		BlockHash:     hash,
+		ParentHash:    parent,
+		FeeRecipient:  "0x0000000000000000000000000000000000000000",
+		StateRoot:     "0x00",
+		ReceiptsRoot:  "0x00",
+		LogsBloom:     "0x00",
+		Random:        "0x00",
+		GasLimit:      "0x1c9c380",
+		GasUsed:       "0x0",
+		ExtraData:     "0x",
+		BaseFeePerGas: "0x7",
+		Transactions:  []string{},
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		conf Config
+		err  bool
+	}{
+		{name: "engine", conf: Config{EngineURL: "http://127.0.0.1:8551"}},
+		{name: "metrics", conf: Config{MetricsURL: "http://127.0.0.1:9090"}},
+		{name: "none", conf: Config{}, err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := New(&test.conf); (err != nil) != test.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestClientEngine(t *testing.T) {
+	c := newTestClient(t, testSecret)
+	ctx := context.Background()
+
+	status, err := c.NewPayloadV1(ctx, testPayload("0x1", "0x01", "0x00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status.Status != exec.StatusValid || status.LatestValidHash != "0x01" {
+		t.Fatalf("newPayload returned %+v", status)
+	}
+
+	updated, err := c.ForkchoiceUpdatedV1(ctx, &exec.RequestParamsForkchoiceUpdatedV1{HeadBlockHash: "0x01", SafeBlockHash: "0x01", FinalizedBlockHash: "0x00"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if updated.PayloadStatus.Status != exec.StatusValid {
+		t.Fatalf("forkchoiceUpdated returned %+v", updated)
+	}
+
+	tests := []struct {
+		name     string
+		call     func() (interface{}, error)
+		expected interface{}
+	}{
+		{
+			name:     "chain id",
+			call:     func() (interface{}, error) { return c.ChainID(ctx) },
+			expected: "0x539",
+		},
+		{
+			name:     "block number",
+			call:     func() (interface{}, error) { return c.BlockNumber(ctx) },
+			expected: "0x1",
+		},
+		{
+			name: "known block",
+			call: func() (interface{}, error) {
+				block, ok, err := c.GetBlockByHash(ctx, "0x01")
+				if err != nil || !ok {
+					return ok, err
+				}
+
+				return block.Number, nil
+			},
+			expected: "0x1",
+		},
+		{
+			name: "unknown block",
+			call: func() (interface{}, error) {
+				_, ok, err := c.GetBlockByHash(ctx, "0x02")
+
+				return ok, err
+			},
+			expected: false,
+		},
+		{
+			name: "latest block",
+			call: func() (interface{}, error) {
+				block, ok, err := c.GetBlockByNumber(ctx, "latest")
+				if err != nil || !ok {
+					return ok, err
+				}
+
+				return block.Hash, nil
+			},
+			expected: "0x01",
+		},
+		{
+			name: "capabilities",
+			call: func() (interface{}, error) {
+				capabilities, err := c.ExchangeCapabilities(ctx, []string{"engine_newPayloadV1"})
+
+				return len(capabilities) > 0 && capabilities[0] == "engine_exchangeTransitionConfigurationV1", err
+			},
+			expected: true,
+		},
+		{
+			name: "client version",
+			call: func() (interface{}, error) {
+				versions, err := c.GetClientVersionV1(ctx, &exec.ClientVersionV1{Code: "LH", Name: "lighthouse", Version: "v5.0.0", Commit: "0x01020304"})
+				if err != nil || len(versions) != 1 {
+					return len(versions), err
+				}
+
+				return versions[0].Name, nil
+			},
+			expected: "stubbies",
+		},
+		{
+			name: "transition configuration",
+			call: func() (interface{}, error) {
+				conf, err := c.ExchangeTransitionConfigurationV1(ctx, &exec.ResultExchangeTransitionConfigurationV1{TerminalTotalDifficulty: "0x0"})
+				if err != nil {
+					return nil, err
+				}
+
+				return conf.TerminalBlockNumber, nil
+			},
+			expected: "0x0",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.call()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Fatalf("returned %v, expected %v", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	authenticated := newTestClient(t, testSecret)
+	unauthenticated := newTestClient(t, "")
+
+	engineOnly, err := New(&Config{EngineURL: "http://127.0.0.1:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		call func() error
+		// code is the JSON-RPC error code expected, 0 for any other error.
+		code int
+	}{
+		{
+			name: "unknown method",
+			call: func() error { return authenticated.Call(ctx, "eth_unknown", nil) },
+			code: exec.ErrorCodeMethodNotFound,
+		},
+		{
+			name: "unauthenticated",
+			call: func() error {
+				_, err := unauthenticated.ChainID(ctx)
+
+				return err
+			},
+		},
+		{
+			name: "no metrics url",
+			call: func() error {
+				_, err := engineOnly.Health(ctx)
+
+				return err
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.call()
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			var rpcErr *exec.ResponseError
+
+			if isRPCErr := errors.As(err, &rpcErr); isRPCErr != (test.code != 0) || (isRPCErr && rpcErr.Code != test.code) {
+				t.Fatalf("returned %v, expected code %d", err, test.code)
+			}
+		})
+	}
+}
+
+func TestClientAdmin(t *testing.T) {
+	c := newTestClient(t, testSecret)
+	ctx := context.Background()
+
+	for name, check := range map[string]func(context.Context) (*health.Response, error){
+		"health": c.Health,
+		"ready":  c.Ready,
+		"live":   c.Live,
+	} {
+		rsp, err := check(ctx)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if rsp.Status == "" {
+			t.Fatalf("%s returned no status", name)
+		}
+	}
+
+	events, err := c.Events(ctx, EventsFilter{Methods: []string{"engine_newPayload"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.ChainID(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.NewPayloadV1(ctx, testPayload("0x1", "0x01", "0x00")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case activity := <-events:
+		if activity.Method != "engine_newPayloadV1" || activity.BlockHash != "0x01" || activity.Status != exec.StatusValid {
+			t.Fatalf("streamed %+v", activity)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no activity streamed")
+	}
+
+	clients, err := c.Clients(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(clients["default"]) != 1 {
+		t.Fatalf("clients are %+v, expected one client of the default tenant", clients)
+	}
+}
+
+func TestReadEvents(t *testing.T) {
+	tests := []struct {
+		name     string
+		stream   string
+		expected []string
+	}{
+		{
+			name:     "events",
+			stream:   "event: engine_call\ndata: {\"method\":\"engine_newPayloadV1\"}\n\nevent: engine_call\ndata: {\"method\":\"engine_forkchoiceUpdatedV1\"}\n\n",
+			expected: []string{"engine_newPayloadV1", "engine_forkchoiceUpdatedV1"},
+		},
+		{
+			name:     "comments and other events",
+			stream:   ": keepalive\n\nevent: ping\ndata: {\"method\":\"ignored\"}\n\nevent: engine_call\ndata: {\"method\":\"eth_chainId\"}\n\n",
+			expected: []string{"eth_chainId"},
+		},
+		{
+			name:     "data over several lines",
+			stream:   "event: engine_call\ndata: {\"method\":\ndata: \"eth_syncing\"}\n\n",
+			expected: []string{"eth_syncing"},
+		},
+		{
+			name:     "invalid data",
+			stream:   "event: engine_call\ndata: {\n\nevent: engine_call\ndata: {\"method\":\"eth_chainId\"}\n\n",
+			expected: []string{"eth_chainId"},
+		},
+		{
+			name:   "unterminated event",
+			stream: "event: engine_call\ndata: {\"method\":\"eth_chainId\"}\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events := make(chan api.Activity, len(test.expected)+1)
+
+			readEvents(context.Background(), strings.NewReader(test.stream), events)
+			close(events)
+
+			var methods []string
+			for activity := range events {
+				methods = append(methods, activity.Method)
+			}
+
+			if !reflect.DeepEqual(methods, test.expected) {
+				t.Fatalf("read %v, expected %v", methods, test.expected)
+			}
+		})
+	}
+}
+
+func TestEventsFilterQuery(t *testing.T) {
+	tests := []struct {
+		filter   EventsFilter
+		expected string
+	}{
+		{filter: EventsFilter{}, expected: ""},
+		{filter: EventsFilter{Methods: []string{"engine_newPayload", "engine_forkchoiceUpdated"}}, expected: "?method=engine_newPayload%2Cengine_forkchoiceUpdated"},
+		{filter: EventsFilter{Tenants: []string{"a"}, Clients: []string{"lighthouse"}}, expected: "?client=lighthouse&tenant=a"},
+	}
+
+	for _, test := range tests {
+		if got := test.filter.query(); got != test.expected {
+			t.Errorf("%+v encoded to %q, expected %q", test.filter, got, test.expected)
+		}
+	}
+}
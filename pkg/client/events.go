@@ -0,0 +1,124 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ethpandaops/stubbies/pkg/api"
+)
+
+// eventEngineCall is the server-sent event carrying an api.Activity.
+const eventEngineCall = "engine_call"
+
+// EventsFilter selects the activity streamed by Events. Empty lists match everything.
+type EventsFilter struct {
+	Tenants []string
+	// Methods are method prefixes, e.g. "engine_newPayload".
+	Methods []string
+	Clients []string
+}
+
+func (f *EventsFilter) query() string {
+	query := url.Values{}
+
+	for key, values := range map[string][]string{
+		"tenant": f.Tenants,
+		"method": f.Methods,
+		"client": f.Clients,
+	} {
+		if len(values) > 0 {
+			query.Set(key, strings.Join(values, ","))
+		}
+	}
+
+	if len(query) == 0 {
+		return ""
+	}
+
+	return "?" + query.Encode()
+}
+
+// Events streams the engine api calls handled by stubbies from /events. The channel is closed when ctx is
+// cancelled or the stream ends, e.g. because stubbies shut down.
+func (c *Client) Events(ctx context.Context, filter EventsFilter) (<-chan api.Activity, error) {
+	req, err := c.metricsRequest(ctx, "/events"+filter.query())
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "text/event-stream")
+
+	// The stream is long lived, only ctx ends it.
+	rsp, err := (&http.Client{Transport: c.http.Transport}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		defer rsp.Body.Close()
+
+		data, _ := io.ReadAll(rsp.Body)
+
+		return nil, fmt.Errorf("/events returned %s: %s", rsp.Status, strings.TrimSpace(string(data)))
+	}
+
+	events := make(chan api.Activity)
+
+	go func() {
+		defer close(events)
+		defer rsp.Body.Close()
+
+		readEvents(ctx, rsp.Body, events)
+	}()
+
+	return events, nil
+}
+
+func readEvents(ctx context.Context, body io.Reader, events chan<- api.Activity) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var event, data string
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case line == "":
+			// A blank line dispatches the event.
+			if event != eventEngineCall || data == "" {
+				event, data = "", ""
+
+				continue
+			}
+
+			var activity api.Activity
+
+			err := json.Unmarshal([]byte(data), &activity)
+
+			event, data = "", ""
+
+			if err != nil {
+				continue
+			}
+
+			select {
+			case events <- activity:
+			case <-ctx.Done():
+				return
+			}
+		case strings.HasPrefix(line, ":"):
+			// Comments keep the stream alive.
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data += strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")
+		}
+	}
+}
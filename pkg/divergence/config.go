@@ -0,0 +1,34 @@
+package divergence
+
+import (
+	"errors"
+	"time"
+)
+
+type Config struct {
+	Enabled        bool   `yaml:"enabled" default:"false"`
+	SecondsPerSlot uint64 `yaml:"secondsPerSlot" default:"12"`
+	// HeadSlots is the number of slots clients may disagree on head, or on finalized blocks at different
+	// heights, before a divergence is reported. Clients finalizing conflicting blocks are reported right away.
+	HeadSlots uint64 `yaml:"headSlots" default:"2"`
+}
+
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.SecondsPerSlot == 0 {
+		return errors.New("divergence.secondsPerSlot must be greater than 0")
+	}
+
+	if c.HeadSlots == 0 {
+		return errors.New("divergence.headSlots must be greater than 0")
+	}
+
+	return nil
+}
+
+func (c *Config) tolerance() time.Duration {
+	return time.Duration(c.HeadSlots*c.SecondsPerSlot) * time.Second
+}
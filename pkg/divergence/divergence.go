@@ -0,0 +1,224 @@
+package divergence
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethpandaops/stubbies/pkg/webhook"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	KindHead      = "head"
+	KindFinalized = "finalized"
+
+	// EventDiverged and EventConverged are sent to webhooks when a divergence starts and ends.
+	EventDiverged  = webhook.EventForkchoiceDiverged
+	EventConverged = webhook.EventForkchoiceConverged
+
+	checkInterval = time.Second
+	zeroHash      = "0x0000000000000000000000000000000000000000000000000000000000000000"
+)
+
+// View is a consensus client's latest forkchoice state.
+type View struct {
+	Client    string    `json:"client"`
+	Head      string    `json:"head"`
+	Finalized string    `json:"finalized"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// BlockNumberFunc returns the number of a known block.
+type BlockNumberFunc func(hash string) (number uint64, ok bool)
+
+// Monitor compares the forkchoice state of all consensus clients sharing a stubbies instance and
+// reports when they disagree.
+type Monitor struct {
+	log logrus.FieldLogger
+	cfg Config
+
+	views       func() []View
+	blockNumber BlockNumberFunc
+	metrics     Metrics
+	events      *webhook.Dispatcher
+
+	states map[string]*divergence
+
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// divergence tracks a disagreement of a single kind.
+type divergence struct {
+	since    time.Time
+	reported bool
+}
+
+// NewMonitor returns a new Monitor. views is polled for the forkchoice state of every client, events may be nil.
+func NewMonitor(log logrus.FieldLogger, conf *Config, views func() []View, blockNumber BlockNumberFunc, events *webhook.Dispatcher, reg prometheus.Registerer) (*Monitor, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Monitor{
+		log:         log.WithField("module", "divergence"),
+		cfg:         *conf,
+		views:       views,
+		blockNumber: blockNumber,
+		events:      events,
+		metrics:     NewMetrics("divergence", reg),
+		states: map[string]*divergence{
+			KindHead:      {},
+			KindFinalized: {},
+		},
+		done: make(chan struct{}),
+	}, nil
+}
+
+func (m *Monitor) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-m.done:
+				return
+			case now := <-ticker.C:
+				m.check(now)
+			}
+		}
+	}()
+}
+
+// Stop stops the monitor. Calling it again is a no-op.
+func (m *Monitor) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.done)
+	})
+}
+
+func (m *Monitor) check(now time.Time) {
+	tolerance := m.cfg.tolerance()
+
+	// Clients that stopped sending forkchoice updates would otherwise be reported as diverged forever.
+	var active []View
+
+	for _, view := range m.views() {
+		if now.Sub(view.UpdatedAt) <= tolerance {
+			active = append(active, view)
+		}
+	}
+
+	heads := groupBy(active, func(v View) string { return v.Head })
+
+	m.metrics.ObserveClients(len(active), len(heads))
+
+	m.update(KindHead, now, len(heads) > 1, false, active)
+
+	finalized := groupBy(active, func(v View) string { return v.Finalized })
+	delete(finalized, zeroHash)
+	delete(finalized, "")
+
+	m.update(KindFinalized, now, len(finalized) > 1, m.conflictingFinalized(finalized), active)
+}
+
+// conflictingFinalized returns true when clients finalized different blocks at the same height.
+// Clients finalizing blocks at different heights may just be an epoch apart.
+func (m *Monitor) conflictingFinalized(finalized map[string][]string) bool {
+	heights := make(map[uint64]bool, len(finalized))
+
+	for hash := range finalized {
+		number, ok := m.blockNumber(hash)
+		if !ok {
+			continue
+		}
+
+		if heights[number] {
+			return true
+		}
+
+		heights[number] = true
+	}
+
+	return false
+}
+
+// update tracks whether clients disagree, reporting disagreements lasting longer than the tolerance,
+// or immediately when urgent.
+func (m *Monitor) update(kind string, now time.Time, disagree, urgent bool, views []View) {
+	state := m.states[kind]
+
+	if !disagree {
+		if state.reported {
+			m.log.WithField("kind", kind).WithField("duration", now.Sub(state.since).Round(time.Second)).Info("consensus clients agree again")
+			m.metrics.ObserveDivergence(kind, false)
+			m.notify(EventConverged, kind, fmt.Sprintf("consensus clients agree on %s again", kind), views)
+		}
+
+		*state = divergence{}
+
+		return
+	}
+
+	if state.since.IsZero() {
+		state.since = now
+	}
+
+	if state.reported || (!urgent && now.Sub(state.since) <= m.cfg.tolerance()) {
+		return
+	}
+
+	state.reported = true
+
+	m.log.WithFields(logrus.Fields{
+		"kind":    kind,
+		"clients": describe(views, kind),
+	}).Warn("consensus clients disagree on forkchoice")
+	m.metrics.ObserveDivergence(kind, true)
+	m.notify(EventDiverged, kind, fmt.Sprintf("consensus clients disagree on %s", kind), views)
+}
+
+func (m *Monitor) notify(eventType, kind, message string, views []View) {
+	if m.events == nil {
+		return
+	}
+
+	m.events.Send(webhook.NewEvent(eventType, message, map[string]interface{}{
+		"kind":    kind,
+		"clients": views,
+	}))
+}
+
+func groupBy(views []View, key func(View) string) map[string][]string {
+	groups := make(map[string][]string)
+
+	for _, view := range views {
+		groups[key(view)] = append(groups[key(view)], view.Client)
+	}
+
+	return groups
+}
+
+// describe renders the relevant hash of every client, e.g. "lighthouse=0xab.. prysm=0xcd..".
+func describe(views []View, kind string) string {
+	parts := make([]string, 0, len(views))
+
+	for _, view := range views {
+		hash := view.Head
+		if kind == KindFinalized {
+			hash = view.Finalized
+		}
+
+		parts = append(parts, fmt.Sprintf("%s=%s", view.Client, hash))
+	}
+
+	sort.Strings(parts)
+
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,133 @@
+package divergence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+)
+
+// view is a client's forkchoice at a check, last updated stale before it.
+type view struct {
+	client, head, finalized string
+	stale                   time.Duration
+}
+
+type check struct {
+	at    time.Duration
+	views []view
+	// head and finalized are whether a divergence is reported after the check.
+	head, finalized bool
+}
+
+func TestMonitorCheck(t *testing.T) {
+	// Blocks 0xa1 and 0xa2 are both at height 10, 0xb at 42.
+	numbers := map[string]uint64{"0xa1": 10, "0xa2": 10, "0xb": 42}
+
+	tests := []struct {
+		name   string
+		checks []check
+		// divergences is the number of divergences reported by kind.
+		divergences map[string]float64
+	}{
+		{
+			name: "agreeing clients",
+			checks: []check{
+				{views: []view{{"lighthouse", "0x1", "0xa1", 0}, {"prysm", "0x1", "0xa1", 0}}},
+				{at: 5 * time.Second, views: []view{{"lighthouse", "0x2", "0xa1", 0}, {"prysm", "0x2", zeroHash, 0}}},
+			},
+		},
+		{
+			name: "head divergence within tolerance",
+			checks: []check{
+				{views: []view{{"lighthouse", "0x1", "", 0}, {"prysm", "0x2", "", 0}}},
+				{at: 2 * time.Second, views: []view{{"lighthouse", "0x1", "", 0}, {"prysm", "0x2", "", 0}}},
+				{at: 3 * time.Second, views: []view{{"lighthouse", "0x3", "", 0}, {"prysm", "0x3", "", 0}}},
+			},
+		},
+		{
+			name: "head divergence reported once and converging",
+			checks: []check{
+				{views: []view{{"lighthouse", "0x1", "", 0}, {"prysm", "0x2", "", 0}}},
+				{at: 3 * time.Second, views: []view{{"lighthouse", "0x1", "", 0}, {"prysm", "0x2", "", 0}}, head: true},
+				{at: 4 * time.Second, views: []view{{"lighthouse", "0x1", "", 0}, {"prysm", "0x3", "", 0}}, head: true},
+				{at: 5 * time.Second, views: []view{{"lighthouse", "0x3", "", 0}, {"prysm", "0x3", "", 0}}},
+			},
+			divergences: map[string]float64{KindHead: 1},
+		},
+		{
+			name: "stale clients are ignored",
+			checks: []check{
+				{views: []view{{"lighthouse", "0x1", "", 0}, {"prysm", "0x2", "", 0}}},
+				{at: 3 * time.Second, views: []view{{"lighthouse", "0x1", "", 0}, {"prysm", "0x2", "", 3 * time.Second}}},
+			},
+		},
+		{
+			name: "conflicting finalized blocks reported right away",
+			checks: []check{
+				{views: []view{{"lighthouse", "0x1", "0xa1", 0}, {"prysm", "0x1", "0xa2", 0}}, finalized: true},
+			},
+			divergences: map[string]float64{KindFinalized: 1},
+		},
+		{
+			name: "finalized blocks at different heights",
+			checks: []check{
+				{views: []view{{"lighthouse", "0x1", "0xa1", 0}, {"prysm", "0x1", "0xb", 0}}},
+				{at: 3 * time.Second, views: []view{{"lighthouse", "0x1", "0xa1", 0}, {"prysm", "0x1", "0xb", 0}}, finalized: true},
+			},
+			divergences: map[string]float64{KindFinalized: 1},
+		},
+		{
+			name: "unknown finalized blocks",
+			checks: []check{
+				{views: []view{{"lighthouse", "0x1", "0xc1", 0}, {"prysm", "0x1", "0xc2", 0}}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			start := time.Now()
+
+			var current []View
+
+			m, err := NewMonitor(logrus.New(), &Config{Enabled: true, SecondsPerSlot: 1, HeadSlots: 2}, func() []View {
+				return current
+			}, func(hash string) (uint64, bool) {
+				number, ok := numbers[hash]
+
+				return number, ok
+			}, nil, prometheus.NewRegistry())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, c := range test.checks {
+				now := start.Add(c.at)
+
+				current = nil
+				for _, v := range c.views {
+					current = append(current, View{Client: v.client, Head: v.head, Finalized: v.finalized, UpdatedAt: now.Add(-v.stale)})
+				}
+
+				m.check(now)
+
+				if head := testutil.ToFloat64(m.metrics.headDiverged) == 1; head != c.head {
+					t.Errorf("check %d: head diverged is %t, expected %t", i, head, c.head)
+				}
+
+				if finalized := testutil.ToFloat64(m.metrics.finalizedDiverged) == 1; finalized != c.finalized {
+					t.Errorf("check %d: finalized diverged is %t, expected %t", i, finalized, c.finalized)
+				}
+			}
+
+			for _, kind := range []string{KindHead, KindFinalized} {
+				if got := testutil.ToFloat64(m.metrics.divergences.WithLabelValues(kind)); got != test.divergences[kind] {
+					t.Errorf("%v %s divergences reported, expected %v", got, kind, test.divergences[kind])
+				}
+			}
+		})
+	}
+}
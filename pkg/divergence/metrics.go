@@ -0,0 +1,72 @@
+package divergence
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type Metrics struct {
+	clients           prometheus.Gauge
+	heads             prometheus.Gauge
+	headDiverged      prometheus.Gauge
+	finalizedDiverged prometheus.Gauge
+	divergences       *prometheus.CounterVec
+}
+
+func NewMetrics(namespace string, reg prometheus.Registerer) Metrics {
+	m := Metrics{
+		clients: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "clients",
+			Help:      "Number of consensus clients that sent a forkchoice update recently",
+		}),
+		heads: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "distinct_heads",
+			Help:      "Number of distinct head blocks across consensus clients",
+		}),
+		headDiverged: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "head_diverged",
+			Help:      "1 while consensus clients disagree on head for longer than the tolerance",
+		}),
+		finalizedDiverged: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "finalized_diverged",
+			Help:      "1 while consensus clients disagree on the finalized block",
+		}),
+		divergences: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "divergences_total",
+			Help:      "Number of divergences detected, by kind",
+		}, []string{"kind"}),
+	}
+
+	reg.MustRegister(m.clients)
+	reg.MustRegister(m.heads)
+	reg.MustRegister(m.headDiverged)
+	reg.MustRegister(m.finalizedDiverged)
+	reg.MustRegister(m.divergences)
+
+	return m
+}
+
+func (m Metrics) ObserveClients(clients, heads int) {
+	m.clients.Set(float64(clients))
+	m.heads.Set(float64(heads))
+}
+
+func (m Metrics) ObserveDivergence(kind string, diverged bool) {
+	gauge := m.headDiverged
+	if kind == KindFinalized {
+		gauge = m.finalizedDiverged
+	}
+
+	if !diverged {
+		gauge.Set(0)
+
+		return
+	}
+
+	gauge.Set(1)
+	m.divergences.WithLabelValues(kind).Inc()
+}
@@ -0,0 +1,64 @@
+package drive
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethpandaops/stubbies/pkg/rpc"
+)
+
+const (
+	// ModeBuild asks the execution client to build a payload every slot, like a proposing consensus client would.
+	ModeBuild = "build"
+	// ModeReplay sends the newPayload and forkchoiceUpdated calls recorded in a capture file.
+	ModeReplay = "replay"
+)
+
+type Config struct {
+	Upstream rpc.Config
+
+	Mode        string
+	CapturePath string
+
+	SlotDuration time.Duration
+	// Slots is the number of slots to drive, 0 runs until interrupted or the capture is exhausted.
+	Slots int
+	// BuildTime is how long the execution client gets to build a payload before it is fetched.
+	BuildTime     time.Duration
+	EngineVersion int
+	FeeRecipient  string
+}
+
+func (c *Config) Validate() error {
+	if err := c.Upstream.Validate(); err != nil {
+		return err
+	}
+
+	switch c.Mode {
+	case ModeBuild:
+		if c.EngineVersion < 1 || c.EngineVersion > 3 {
+			return errors.New("engine version must be 1, 2 or 3")
+		}
+
+		if c.BuildTime >= c.SlotDuration {
+			return errors.New("build time must be shorter than the slot duration")
+		}
+	case ModeReplay:
+		if c.CapturePath == "" {
+			return errors.New("capture path is required in replay mode")
+		}
+	default:
+		return fmt.Errorf("mode must be one of %q or %q", ModeBuild, ModeReplay)
+	}
+
+	if c.SlotDuration <= 0 {
+		return errors.New("slot duration must be positive")
+	}
+
+	if c.Slots < 0 {
+		return errors.New("slots must be positive")
+	}
+
+	return nil
+}
@@ -0,0 +1,371 @@
+package drive
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethpandaops/stubbies/pkg/capture"
+	"github.com/ethpandaops/stubbies/pkg/execution"
+	"github.com/ethpandaops/stubbies/pkg/rpc"
+	"github.com/sirupsen/logrus"
+)
+
+// Driver acts as a minimal consensus client, driving an execution client via the engine api.
+type Driver struct {
+	log logrus.FieldLogger
+	cfg Config
+
+	client *rpc.Client
+	stats  *Stats
+
+	headHash      string
+	headTimestamp uint64
+}
+
+type getPayloadResult struct {
+	ExecutionPayload json.RawMessage `json:"executionPayload"`
+	BlobsBundle      *struct {
+		Commitments []string `json:"commitments"`
+	} `json:"blobsBundle"`
+}
+
+type payloadHeader struct {
+	BlockHash   string `json:"blockHash"`
+	BlockNumber string `json:"blockNumber"`
+	Timestamp   string `json:"timestamp"`
+}
+
+func NewDriver(log logrus.FieldLogger, conf *Config) (*Driver, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	client, err := rpc.NewClientFromConfig(&conf.Upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Driver{
+		log:    log.WithField("module", "drive"),
+		cfg:    *conf,
+		client: client,
+		stats:  NewStats(),
+	}, nil
+}
+
+// Run drives the execution client until the configured number of slots has passed or ctx is cancelled,
+// logging a latency and status summary before returning.
+func (d *Driver) Run(ctx context.Context) error {
+	defer d.stats.Log(d.log)
+
+	d.log.WithFields(logrus.Fields{
+		"target": d.client.URL(),
+		"mode":   d.cfg.Mode,
+	}).Info("driving execution client")
+
+	if d.cfg.Mode == ModeReplay {
+		return d.runReplay(ctx)
+	}
+
+	return d.runBuild(ctx)
+}
+
+func (d *Driver) runBuild(ctx context.Context) error {
+	var head execution.ResultGetBlock
+	if err := d.client.Call(ctx, "eth_getBlockByNumber", []interface{}{"latest", false}, &head); err != nil {
+		return fmt.Errorf("failed to fetch head block: %w", err)
+	}
+
+	timestamp, err := parseHexUint64(head.Timestamp)
+	if err != nil {
+		return err
+	}
+
+	d.headHash = head.Hash
+	d.headTimestamp = timestamp
+
+	return d.everySlot(ctx, func(slot int) error {
+		if err := d.buildBlock(ctx, slot); err != nil {
+			d.log.WithError(err).WithField("slot", slot).Warn("failed to build block")
+		}
+
+		return nil
+	})
+}
+
+func (d *Driver) runReplay(ctx context.Context) error {
+	entries, err := capture.ReadFile(d.cfg.CapturePath)
+	if err != nil {
+		return err
+	}
+
+	// Group the recorded calls into slots, each starting with a newPayload.
+	var slots [][]*capture.Entry
+
+	for _, entry := range entries {
+		switch {
+		case strings.HasPrefix(entry.Method, "engine_newPayload"):
+			slots = append(slots, []*capture.Entry{entry})
+		case strings.HasPrefix(entry.Method, "engine_forkchoiceUpdated"):
+			if len(slots) == 0 {
+				slots = append(slots, nil)
+			}
+
+			slots[len(slots)-1] = append(slots[len(slots)-1], entry)
+		}
+	}
+
+	d.log.WithField("slots", len(slots)).Info("loaded capture")
+
+	return d.everySlot(ctx, func(slot int) error {
+		if slot >= len(slots) {
+			return errSlotsExhausted
+		}
+
+		for _, entry := range slots[slot] {
+			var request struct {
+				Params []*json.RawMessage `json:"params"`
+			}
+
+			if err := json.Unmarshal(entry.Request, &request); err != nil {
+				return err
+			}
+
+			var result json.RawMessage
+			if err := d.call(ctx, entry.Method, request.Params, &result); err != nil {
+				d.log.WithError(err).WithFields(logrus.Fields{"slot": slot, "method": entry.Method}).Warn("call failed")
+			}
+		}
+
+		return nil
+	})
+}
+
+var errSlotsExhausted = errors.New("slots exhausted")
+
+func (d *Driver) everySlot(ctx context.Context, fn func(slot int) error) error {
+	ticker := time.NewTicker(d.cfg.SlotDuration)
+	defer ticker.Stop()
+
+	for slot := 0; d.cfg.Slots == 0 || slot < d.cfg.Slots; slot++ {
+		if err := fn(slot); err != nil {
+			if errors.Is(err, errSlotsExhausted) {
+				return nil
+			}
+
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
+
+func (d *Driver) buildBlock(ctx context.Context, slot int) error {
+	version := strconv.Itoa(d.cfg.EngineVersion)
+
+	timestamp := uint64(time.Now().Unix())
+	if timestamp <= d.headTimestamp {
+		timestamp = d.headTimestamp + 1
+	}
+
+	attributes := execution.RequestParamsPayloadAttributes{
+		Timestamp:             fmt.Sprintf("0x%x", timestamp),
+		PrevRandao:            randomHash(),
+		SuggestedFeeRecipient: d.cfg.FeeRecipient,
+	}
+
+	if d.cfg.EngineVersion >= 2 {
+		attributes.Withdrawals = &[]execution.RequestParamsWithdrawal{}
+	}
+
+	beaconRoot := randomHash()
+	if d.cfg.EngineVersion >= 3 {
+		attributes.ParentBeaconBlockRoot = &beaconRoot
+	}
+
+	var forkchoice execution.ResultForkchoiceUpdatedV1
+	if err := d.call(ctx, "engine_forkchoiceUpdatedV"+version, []interface{}{d.forkchoiceState(), attributes}, &forkchoice); err != nil {
+		return err
+	}
+
+	if forkchoice.PayloadID == "" {
+		return fmt.Errorf("no payload id returned, status %s", forkchoice.PayloadStatus.Status)
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-time.After(d.cfg.BuildTime):
+	}
+
+	payload, versionedHashes, err := d.getPayload(ctx, version, forkchoice.PayloadID)
+	if err != nil {
+		return err
+	}
+
+	var header payloadHeader
+	if err := json.Unmarshal(payload, &header); err != nil {
+		return err
+	}
+
+	params := []interface{}{payload}
+	if d.cfg.EngineVersion >= 3 {
+		params = append(params, versionedHashes, beaconRoot)
+	}
+
+	var status execution.ResultNewPayloadV1
+	if err := d.call(ctx, "engine_newPayloadV"+version, params, &status); err != nil {
+		return err
+	}
+
+	if status.Status != "VALID" {
+		return fmt.Errorf("payload %s returned status %s: %s", header.BlockHash, status.Status, status.ValidationError)
+	}
+
+	d.headHash = header.BlockHash
+	d.headTimestamp = timestamp
+
+	if err := d.call(ctx, "engine_forkchoiceUpdatedV"+version, []interface{}{d.forkchoiceState(), nil}, &forkchoice); err != nil {
+		return err
+	}
+
+	d.log.WithFields(logrus.Fields{
+		"slot":   slot,
+		"number": header.BlockNumber,
+		"hash":   header.BlockHash,
+	}).Info("built block")
+
+	return nil
+}
+
+func (d *Driver) getPayload(ctx context.Context, version, payloadID string) (payload json.RawMessage, versionedHashes []string, err error) {
+	method := "engine_getPayloadV" + version
+
+	if version == "1" {
+		err = d.call(ctx, method, []interface{}{payloadID}, &payload)
+
+		return payload, nil, err
+	}
+
+	var result getPayloadResult
+	if err := d.call(ctx, method, []interface{}{payloadID}, &result); err != nil {
+		return nil, nil, err
+	}
+
+	versionedHashes = []string{}
+
+	if result.BlobsBundle != nil {
+		for _, commitment := range result.BlobsBundle.Commitments {
+			hash, err := kzgToVersionedHash(commitment)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			versionedHashes = append(versionedHashes, hash)
+		}
+	}
+
+	return result.ExecutionPayload, versionedHashes, nil
+}
+
+func (d *Driver) forkchoiceState() execution.RequestParamsForkchoiceUpdatedV1 {
+	return execution.RequestParamsForkchoiceUpdatedV1{
+		HeadBlockHash:      d.headHash,
+		SafeBlockHash:      d.headHash,
+		FinalizedBlockHash: d.headHash,
+	}
+}
+
+// call makes a request, recording its latency and payload status (or "ok"/"error" for other results).
+func (d *Driver) call(ctx context.Context, method string, params, result interface{}) error {
+	var raw json.RawMessage
+
+	start := time.Now()
+	err := d.client.Call(ctx, method, params, &raw)
+	latency := time.Since(start)
+
+	if err != nil {
+		d.stats.Record(method, "error", latency)
+
+		return err
+	}
+
+	d.stats.Record(method, payloadStatus(raw), latency)
+
+	d.log.WithFields(logrus.Fields{
+		"method":  method,
+		"latency": latency,
+	}).Debug("called execution client")
+
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(raw, result)
+}
+
+func payloadStatus(raw json.RawMessage) string {
+	var result struct {
+		Status        string `json:"status"`
+		PayloadStatus *struct {
+			Status string `json:"status"`
+		} `json:"payloadStatus"`
+	}
+
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return "ok"
+	}
+
+	switch {
+	case result.PayloadStatus != nil:
+		return result.PayloadStatus.Status
+	case result.Status != "":
+		return result.Status
+	}
+
+	return "ok"
+}
+
+func kzgToVersionedHash(commitment string) (string, error) {
+	data, err := hex.DecodeString(strings.TrimPrefix(commitment, "0x"))
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(data)
+	hash[0] = 0x01
+
+	return "0x" + hex.EncodeToString(hash[:]), nil
+}
+
+func randomHash() string {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return "0x" + hex.EncodeToString(b)
+}
+
+func parseHexUint64(s string) (uint64, error) {
+	if !strings.HasPrefix(s, "0x") {
+		return 0, fmt.Errorf("invalid hex number %q", s)
+	}
+
+	return strconv.ParseUint(s[2:], 16, 64)
+}
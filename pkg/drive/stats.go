@@ -0,0 +1,82 @@
+package drive
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Stats collects latency and status of every call made to the execution client.
+type Stats struct {
+	methods map[string]*methodStats
+
+	mu sync.Mutex
+}
+
+type methodStats struct {
+	latencies []time.Duration
+	statuses  map[string]int
+}
+
+func NewStats() *Stats {
+	return &Stats{
+		methods: make(map[string]*methodStats),
+	}
+}
+
+func (s *Stats) Record(method, status string, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.methods[method]
+	if !ok {
+		m = &methodStats{statuses: make(map[string]int)}
+		s.methods[method] = m
+	}
+
+	m.latencies = append(m.latencies, latency)
+	m.statuses[status]++
+}
+
+// Log writes a summary line per method.
+func (s *Stats) Log(log logrus.FieldLogger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	methods := make([]string, 0, len(s.methods))
+	for method := range s.methods {
+		methods = append(methods, method)
+	}
+
+	sort.Strings(methods)
+
+	for _, method := range methods {
+		m := s.methods[method]
+
+		latencies := make([]time.Duration, len(m.latencies))
+		copy(latencies, m.latencies)
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+		var total time.Duration
+		for _, l := range latencies {
+			total += l
+		}
+
+		log.WithFields(logrus.Fields{
+			"method":   method,
+			"calls":    len(latencies),
+			"statuses": m.statuses,
+			"min":      latencies[0],
+			"avg":      total / time.Duration(len(latencies)),
+			"p50":      percentile(latencies, 0.5),
+			"p95":      percentile(latencies, 0.95),
+			"max":      latencies[len(latencies)-1],
+		}).Info("summary")
+	}
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	return sorted[int(float64(len(sorted)-1)*p)]
+}
@@ -0,0 +1,40 @@
+package execution
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Backend answers JSON-RPC requests on behalf of the API. Handler is the stub backend,
+// other backends (e.g. proxying to a real execution client) typically wrap it.
+type Backend interface {
+	Start(ctx context.Context) error
+	// Stop releases the backend's resources. In-flight requests have been drained by the time it is called.
+	Stop(ctx context.Context) error
+	Request(ctx context.Context, id int, method string, params []*json.RawMessage) (*Response, error)
+	// SubscribeNewHeads returns a channel receiving the header of every new head, and a func to unsubscribe.
+	SubscribeNewHeads() (<-chan *ResultHeader, func())
+	// LastEngineCall returns when the last engine_* request was received, or the zero time if none has been.
+	LastEngineCall() time.Time
+}
+
+// DefaultClient is the client of requests whose context carries none.
+const DefaultClient = "unknown"
+
+type clientContextKey struct{}
+
+// WithClient returns a copy of ctx carrying the consensus client a request came from, used to label per client
+// metrics. client needs to be suitable for a metric label.
+func WithClient(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, clientContextKey{}, client)
+}
+
+// ClientFromContext returns the consensus client carried by ctx, or DefaultClient.
+func ClientFromContext(ctx context.Context) string {
+	if client, ok := ctx.Value(clientContextKey{}).(string); ok && client != "" {
+		return client
+	}
+
+	return DefaultClient
+}
@@ -30,3 +30,26 @@ func (b *Block) GetResult() *ResultGetBlock {
 		Transactions: b.payload.Transactions,
 	}
 }
+
+func (b *Block) GetHeader() *ResultHeader {
+	if b.payload == nil {
+		return nil
+	}
+
+	return &ResultHeader{
+		Number:        b.payload.BlockNumber,
+		Hash:          b.payload.BlockHash,
+		ParentHash:    b.payload.ParentHash,
+		Miner:         b.payload.FeeRecipient,
+		LogsBloom:     b.payload.LogsBloom,
+		StateRoot:     b.payload.StateRoot,
+		ReceiptsRoot:  b.payload.ReceiptsRoot,
+		MixHash:       b.payload.Random,
+		Difficulty:    "0x0",
+		ExtraData:     b.payload.ExtraData,
+		GasLimit:      b.payload.GasLimit,
+		GasUsed:       b.payload.GasUsed,
+		Timestamp:     b.payload.Timestamp,
+		BaseFeePerGas: b.payload.BaseFeePerGas,
+	}
+}
@@ -0,0 +1,97 @@
+package execution
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// observeForkchoice records the head, safe and finalized blocks and detects reorgs of the head.
+func (h *Handler) observeForkchoice(previous, current RequestParamsForkchoiceUpdatedV1) {
+	h.metrics.ObserveStoredBlocks(h.storage.Count())
+
+	h.observeFinalized(previous, current)
+
+	for kind, hash := range map[string]string{
+		"head":      current.HeadBlockHash,
+		"safe":      current.SafeBlockHash,
+		"finalized": current.FinalizedBlockHash,
+	} {
+		if block := h.storage.GetBlockByHash(hash); block != nil {
+			h.metrics.ObserveForkchoiceBlock(kind, block)
+		}
+	}
+
+	if previous.HeadBlockHash == "" || previous.HeadBlockHash == current.HeadBlockHash {
+		return
+	}
+
+	head := h.storage.GetBlockByHash(current.HeadBlockHash)
+	if head == nil || head.payload.ParentHash == previous.HeadBlockHash {
+		return
+	}
+
+	depth, ok := h.reorgDepth(previous.HeadBlockHash, current.HeadBlockHash)
+	if !ok {
+		// Skipping ahead over blocks we have not seen, or moving to a block we don't know, is not a reorg.
+		return
+	}
+
+	if depth == 0 {
+		// The new head descends from the previous head.
+		return
+	}
+
+	h.metrics.ObserveReorg(depth)
+
+	h.log.WithFields(logrus.Fields{
+		"depth":         depth,
+		"previous_head": previous.HeadBlockHash,
+		"head":          current.HeadBlockHash,
+	}).Info("reorg")
+
+	if depth >= h.Cfg.Events.MinReorgDepth {
+		h.emit(EventReorg, fmt.Sprintf("reorg of depth %d", depth), map[string]interface{}{
+			"depth":        depth,
+			"previousHead": previous.HeadBlockHash,
+			"head":         current.HeadBlockHash,
+		})
+	}
+}
+
+// reorgDepth returns the number of blocks on the old head's chain that are not on the new head's chain.
+// ok is false when the common ancestor can not be found in storage.
+func (h *Handler) reorgDepth(oldHash, newHash string) (depth int, ok bool) {
+	oldBlock := h.storage.GetBlockByHash(oldHash)
+	newBlock := h.storage.GetBlockByHash(newHash)
+
+	for oldBlock != nil && newBlock != nil {
+		if oldBlock.payload.BlockHash == newBlock.payload.BlockHash {
+			return depth, true
+		}
+
+		if oldBlock.Number.Cmp(newBlock.Number) >= 0 {
+			oldBlock = h.storage.GetBlockByHash(oldBlock.payload.ParentHash)
+			depth++
+		} else {
+			newBlock = h.storage.GetBlockByHash(newBlock.payload.ParentHash)
+		}
+	}
+
+	return 0, false
+}
+
+// blobCount returns the number of blob versioned hashes passed to engine_newPayloadV3.
+func blobCount(params []*json.RawMessage) int {
+	if len(params) < 2 || params[1] == nil {
+		return 0
+	}
+
+	var hashes []string
+	if err := json.Unmarshal(*params[1], &hashes); err != nil {
+		return 0
+	}
+
+	return len(hashes)
+}
@@ -1,12 +1,163 @@
 package execution
 
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethpandaops/stubbies/pkg/version"
+)
+
 type Config struct {
 	ChainID                 string `yaml:"chainId" default:"0x1"`
 	TerminalTotalDifficulty string `yaml:"terminalTotalDifficulty" default:"0x0"`
 	TerminalBlockHash       string `yaml:"terminalBlockHash" default:"0x0000000000000000000000000000000000000000000000000000000000000000"`
 	TerminalBlockNumber     string `yaml:"terminalBlockNumber" default:"0x0"`
+
+	// ClientVersion is returned by web3_clientVersion and admin_nodeInfo. Defaults to the stubbies build version.
+	ClientVersion string `yaml:"clientVersion"`
+	// NetworkID is returned by net_version. Defaults to the decimal representation of ChainID.
+	NetworkID string `yaml:"networkId"`
+	PeerCount string `yaml:"peerCount" default:"0x0"`
+
+	// ClientIdentity is returned by engine_getClientVersionV1. Unset fields default to the stubbies build info.
+	ClientIdentity ClientIdentityConfig `yaml:"clientIdentity"`
+
+	// Replay answers requests with responses from a capture file.
+	Replay ReplayConfig `yaml:"replay"`
+
+	// SlotTiming relates engine api calls to beacon chain slots.
+	SlotTiming SlotTimingConfig `yaml:"slotTiming"`
+
+	// Scenario scripts the behaviour of newPayload and forkchoiceUpdated over slots, epochs and blocks.
+	Scenario ScenarioConfig `yaml:"scenario"`
+
+	// Script lets a Starlark script answer, fail or delay requests before the stub logic.
+	Script ScriptConfig `yaml:"script"`
+
+	// Methods answers methods with templated results or fixed errors, before the built-in methods.
+	Methods []MethodConfig `yaml:"methods"`
+	// UnknownMethods is the answer to methods without a built-in or configured answer: "false" or "error"
+	// for a -32601 method not found error.
+	UnknownMethods string `yaml:"unknownMethods" default:"false"`
+
+	// Events configures the events sent to webhooks.
+	Events EventsConfig `yaml:"events"`
+}
+
+type ClientIdentityConfig struct {
+	Code    string `yaml:"code" default:"SB"`
+	Name    string `yaml:"name" default:"stubbies"`
+	Version string `yaml:"version"`
+	Commit  string `yaml:"commit"`
+}
+
+func (c *ClientIdentityConfig) Validate() error {
+	if len(c.Code) != 2 {
+		return errors.New("clientIdentity.code must be two characters")
+	}
+
+	if c.Commit != "" && !strings.HasPrefix(c.Commit, "0x") {
+		return errors.New("clientIdentity.commit must be hex encoded")
+	}
+
+	return nil
+}
+
+func (c *ClientIdentityConfig) clientVersion() ClientVersionV1 {
+	v := ClientVersionV1{
+		Code:    c.Code,
+		Name:    c.Name,
+		Version: c.Version,
+		Commit:  c.Commit,
+	}
+
+	if v.Version == "" {
+		v.Version = version.Release
+	}
+
+	if v.Commit == "" {
+		v.Commit = "0x00000000"
+
+		if commit := version.ShortCommit(); len(commit) == 8 {
+			v.Commit = "0x" + commit
+		}
+	}
+
+	return v
 }
 
 func (c *Config) Validate() error {
+	if _, ok := parseHexBig(c.ChainID); !ok {
+		return errors.New("chainId must be a hex encoded integer")
+	}
+
+	if _, ok := parseHexBig(c.PeerCount); !ok {
+		return errors.New("peerCount must be a hex encoded integer")
+	}
+
+	if c.NetworkID != "" {
+		if _, ok := new(big.Int).SetString(c.NetworkID, 10); !ok {
+			return errors.New("networkId must be a decimal integer")
+		}
+	}
+
+	if err := c.ClientIdentity.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Replay.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.SlotTiming.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Script.Validate(); err != nil {
+		return err
+	}
+
+	for i := range c.Methods {
+		if err := c.Methods[i].Validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.UnknownMethods != UnknownMethodsFalse && c.UnknownMethods != UnknownMethodsError {
+		return fmt.Errorf("unknownMethods must be %s or %s", UnknownMethodsFalse, UnknownMethodsError)
+	}
+
+	if err := c.Events.Validate(); err != nil {
+		return err
+	}
+
 	return nil
 }
+
+func (c *Config) clientVersion() string {
+	if c.ClientVersion != "" {
+		return c.ClientVersion
+	}
+
+	return version.ClientVersion()
+}
+
+func (c *Config) networkID() string {
+	if c.NetworkID != "" {
+		return c.NetworkID
+	}
+
+	chainID, _ := parseHexBig(c.ChainID)
+
+	return chainID.String()
+}
+
+func parseHexBig(s string) (*big.Int, bool) {
+	if !strings.HasPrefix(s, "0x") {
+		return nil, false
+	}
+
+	return new(big.Int).SetString(s[2:], 16)
+}
@@ -0,0 +1,42 @@
+package execution
+
+import (
+	"testing"
+
+	"github.com/creasty/defaults"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		networkID string
+		err       bool
+		expected  string
+	}{
+		{name: "defaults to the chain id", expected: "1"},
+		{name: "decimal", networkID: "1337", expected: "1337"},
+		{name: "hex", networkID: "0x539", err: true},
+		{name: "not a number", networkID: "mainnet", err: true},
+		{name: "negative sign only", networkID: "-", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf := &Config{}
+			if err := defaults.Set(conf); err != nil {
+				t.Fatal(err)
+			}
+
+			conf.NetworkID = test.networkID
+
+			err := conf.Validate()
+			if (err != nil) != test.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !test.err && conf.networkID() != test.expected {
+				t.Fatalf("network id is %s, expected %s", conf.networkID(), test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package execution
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethpandaops/stubbies/pkg/webhook"
+	"github.com/sirupsen/logrus"
+)
+
+// Events sent to webhooks.
+const (
+	EventReorg                 = webhook.EventReorg
+	EventInvalidStatus         = webhook.EventInvalidStatus
+	EventUnknownParent         = webhook.EventUnknownParent
+	EventConsensusClientSilent = webhook.EventConsensusClientSilent
+	EventFinalizedReverted     = webhook.EventFinalizedReverted
+)
+
+type EventsConfig struct {
+	// MinReorgDepth is the minimum depth of a reorg to send a reorg event for.
+	MinReorgDepth int `yaml:"minReorgDepth" default:"2"`
+	// SilentAfter sends a consensus_client_silent event when no engine api call arrived for this long. 0 disables
+	// it, it is only watched when a webhook subscribes to the event.
+	SilentAfter time.Duration `yaml:"silentAfter" default:"1m"`
+}
+
+func (c *EventsConfig) Validate() error {
+	if c.MinReorgDepth < 1 {
+		return errors.New("events.minReorgDepth must be at least 1")
+	}
+
+	if c.SilentAfter < 0 {
+		return errors.New("events.silentAfter must not be negative")
+	}
+
+	return nil
+}
+
+func (h *Handler) emit(eventType, message string, data map[string]interface{}) {
+	if h.events == nil {
+		return
+	}
+
+	h.events.Send(webhook.NewEvent(eventType, message, data))
+}
+
+// ObserveResponse inspects the response returned for an engine api request, e.g. for INVALID payload statuses.
+// Backends answering requests without the handler should pass their responses here.
+func (h *Handler) ObserveResponse(method string, params []*json.RawMessage, resp *Response) {
+	status, ok := ResponsePayloadStatus(method, resp)
+	if !ok {
+		return
+	}
+
+	if strings.HasPrefix(method, "engine_newPayload") {
+		h.metrics.ObservePayloadStatus(method, status.Status)
+	}
+
+	if status.Status != "INVALID" {
+		return
+	}
+
+	hash, _ := RequestBlock(method, params)
+
+	h.emit(EventInvalidStatus, fmt.Sprintf("%s returned INVALID", method), map[string]interface{}{
+		"method":          method,
+		"blockHash":       hash,
+		"latestValidHash": status.LatestValidHash,
+		"validationError": status.ValidationError,
+	})
+}
+
+// ResponsePayloadStatus returns the payload status of a newPayload or forkchoiceUpdated response.
+func ResponsePayloadStatus(method string, resp *Response) (ResultNewPayloadV1, bool) {
+	if resp == nil || resp.Error != nil {
+		return ResultNewPayloadV1{}, false
+	}
+
+	if !strings.HasPrefix(method, "engine_newPayload") && !strings.HasPrefix(method, "engine_forkchoiceUpdated") {
+		return ResultNewPayloadV1{}, false
+	}
+
+	data, err := json.Marshal(resp.Result)
+	if err != nil {
+		return ResultNewPayloadV1{}, false
+	}
+
+	var result struct {
+		ResultNewPayloadV1
+		PayloadStatus *ResultNewPayloadV1 `json:"payloadStatus"`
+	}
+
+	if err := json.Unmarshal(data, &result); err != nil {
+		return ResultNewPayloadV1{}, false
+	}
+
+	if result.PayloadStatus != nil {
+		return *result.PayloadStatus, true
+	}
+
+	return result.ResultNewPayloadV1, result.Status != ""
+}
+
+// RequestBlock returns the payload hash and number of a newPayload request, or the head hash of a forkchoice update.
+func RequestBlock(method string, params []*json.RawMessage) (hash, number string) {
+	if len(params) < 1 || params[0] == nil {
+		return "", ""
+	}
+
+	switch {
+	case strings.HasPrefix(method, "engine_newPayload"):
+		var payload RequestParamsNewPayloadV1
+		if err := json.Unmarshal(*params[0], &payload); err != nil {
+			return "", ""
+		}
+
+		return payload.BlockHash, payload.BlockNumber
+	case strings.HasPrefix(method, "engine_forkchoiceUpdated"):
+		var state RequestParamsForkchoiceUpdatedV1
+		if err := json.Unmarshal(*params[0], &state); err != nil {
+			return "", ""
+		}
+
+		return state.HeadBlockHash, ""
+	}
+
+	return "", ""
+}
+
+// observeUnknownParent sends an event when a payload does not build on any stored block.
+func (h *Handler) observeUnknownParent(payload *RequestParamsNewPayloadV1) {
+	// Every payload has an unknown parent until the first one arrived.
+	if h.storage.Count() == 0 || h.storage.GetBlockByHash(payload.ParentHash) != nil {
+		return
+	}
+
+	h.emit(EventUnknownParent, fmt.Sprintf("payload %s has an unknown parent", payload.BlockHash), map[string]interface{}{
+		"blockHash":   payload.BlockHash,
+		"blockNumber": payload.BlockNumber,
+		"parentHash":  payload.ParentHash,
+	})
+}
+
+// observeFinalized sends an event when the finalized block moves to a lower block number.
+func (h *Handler) observeFinalized(previous, current RequestParamsForkchoiceUpdatedV1) {
+	if previous.FinalizedBlockHash == current.FinalizedBlockHash {
+		return
+	}
+
+	before := h.storage.GetBlockByHash(previous.FinalizedBlockHash)
+	after := h.storage.GetBlockByHash(current.FinalizedBlockHash)
+
+	if before == nil || after == nil || after.Number.Cmp(before.Number) >= 0 {
+		return
+	}
+
+	h.log.WithFields(logrus.Fields{
+		"previous": previous.FinalizedBlockHash,
+		"current":  current.FinalizedBlockHash,
+	}).Warn("finalized block moved backwards")
+
+	h.emit(EventFinalizedReverted, "finalized block moved backwards", map[string]interface{}{
+		"previousHash":   previous.FinalizedBlockHash,
+		"previousNumber": before.Number.Uint64(),
+		"hash":           current.FinalizedBlockHash,
+		"number":         after.Number.Uint64(),
+	})
+}
+
+// watchSilence sends an event once no engine api call arrived for SilentAfter, and again after every
+// silence following a call.
+func (h *Handler) watchSilence() {
+	timeout := h.Cfg.Events.SilentAfter
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	started := time.Now()
+	reported := false
+
+	for {
+		select {
+		case <-h.done:
+			return
+		case now := <-ticker.C:
+			last := h.LastEngineCall()
+			if last.IsZero() {
+				last = started
+			}
+
+			silent := now.Sub(last) > timeout
+
+			if silent && !reported {
+				h.log.WithField("last_call", last).Warn("consensus client went silent")
+
+				h.emit(EventConsensusClientSilent, fmt.Sprintf("no engine api call for %s", now.Sub(last).Round(time.Second)), map[string]interface{}{
+					"lastCall": last.UTC(),
+				})
+			}
+
+			reported = silent
+		}
+	}
+}
@@ -4,137 +4,406 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/ethpandaops/stubbies/pkg/webhook"
+
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 )
 
 var ErrUnsupportedGetBlockQuery = errors.New("unsupported get block query")
 
+// nodeID and nodePubKey are fixed, made up devp2p identifiers advertised by admin_nodeInfo.
+const (
+	nodeID     = "5374756262696573000000000000000000000000000000000000000000000000"
+	nodePubKey = "53747562626965730000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000"
+)
+
 type Handler struct {
 	log logrus.FieldLogger
 	Cfg Config
 
-	storage *Storage
+	storage  *Storage
+	metrics  Metrics
+	replay   *Replayer
+	heads    *headFeed
+	slots    *slotTimer
+	scenario *scenarioRunner
+	script   *Script
+	registry *Registry
+	stubs    []*methodStub
+	events   *webhook.Dispatcher
+
+	lastEngineCall int64
+	done           chan struct{}
+	stopOnce       sync.Once
+
+	consensusClient   *ClientVersionV1
+	consensusClients  map[string]*reportedVersion
+	consensusClientMu sync.Mutex
 }
 
-// NewHandler returns a new Handler instance.
-func NewHandler(log logrus.FieldLogger, conf *Config) *Handler {
+// reportedVersion is the client version a consensus client reported last.
+type reportedVersion struct {
+	version ClientVersionV1
+	at      time.Time
+}
+
+// maxConsensusClients bounds the consensus clients whose version is remembered, the least recently reporting
+// are forgotten first.
+const maxConsensusClients = 256
+
+// NewHandler returns a new Handler instance. events may be nil to disable webhooks.
+func NewHandler(log logrus.FieldLogger, conf *Config, reg prometheus.Registerer, events *webhook.Dispatcher) (*Handler, error) {
 	if err := conf.Validate(); err != nil {
-		log.Fatalf("invalid config: %s", err)
+		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 
-	return &Handler{
-		log:     log.WithField("module", "api/execution"),
-		Cfg:     *conf,
-		storage: newStorage(log.WithField("module", "api/execution/storage")),
+	h := &Handler{
+		log:      log.WithField("module", "api/execution"),
+		Cfg:      *conf,
+		storage:  newStorage(log.WithField("module", "api/execution/storage")),
+		metrics:  NewMetrics("execution", reg),
+		heads:    newHeadFeed(),
+		registry: DefaultRegistry(),
+		events:   events,
+		done:     make(chan struct{}),
+
+		consensusClients: make(map[string]*reportedVersion),
 	}
-}
 
-func (h *Handler) Start(ctx context.Context) {
-	h.storage.Start(ctx)
-}
+	if conf.Replay.Enabled {
+		replay, err := NewReplayer(log.WithField("module", "api/execution/replay"), &conf.Replay)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load replay capture: %w", err)
+		}
 
-func (h *Handler) Request(ctx context.Context, id int, method string, params []*json.RawMessage) (*Response, error) {
-	resp := &Response{
-		ID:      id,
-		JSONRPC: "2.0",
-		Result:  false,
+		h.replay = replay
 	}
 
-	switch method {
-	case "eth_syncing":
-		resp.Result = false
-	case "engine_exchangeTransitionConfigurationV1":
-		resp.Result = ResultExchangeTransitionConfigurationV1{
-			TerminalTotalDifficulty: h.Cfg.TerminalTotalDifficulty,
-			TerminalBlockHash:       h.Cfg.TerminalBlockHash,
-			TerminalBlockNumber:     h.Cfg.TerminalBlockNumber,
-		}
-	case "engine_forkchoiceUpdatedV1":
-		result, err := h.forkChoiceUpdated(params)
+	if conf.SlotTiming.Enabled {
+		slots, err := newSlotTimer(log.WithField("module", "api/execution/slots"), &conf.SlotTiming, h.metrics, h.storage)
 		if err != nil {
 			return nil, err
 		}
 
-		resp.Result = result
-	case "engine_forkchoiceUpdatedV2":
-		result, err := h.forkChoiceUpdated(params)
+		h.slots = slots
+	}
+
+	if conf.Scenario.Path != "" {
+		scenario, err := LoadScenario(conf.Scenario.Path)
 		if err != nil {
 			return nil, err
 		}
 
-		resp.Result = result
-	case "engine_newPayloadV1":
-		result, err := h.newPayload(params)
+		h.scenario = newScenarioRunner(log.WithField("module", "api/execution/scenario"), scenario, h.storage)
+	}
+
+	if conf.Script.Path != "" {
+		script, err := NewScript(log.WithField("module", "api/execution/script"), &conf.Script, h.storage)
 		if err != nil {
 			return nil, err
 		}
 
-		resp.Result = result
-	case "engine_newPayloadV2":
-		result, err := h.newPayload(params)
+		h.script = script
+	}
+
+	for i := range conf.Methods {
+		stub, err := newMethodStub(&conf.Methods[i])
 		if err != nil {
 			return nil, err
 		}
 
-		resp.Result = result
-	case "engine_newPayloadV3":
-		result, err := h.newPayload(params)
+		h.stubs = append(h.stubs, stub)
+	}
+
+	return h, nil
+}
+
+func (h *Handler) Start(ctx context.Context) error {
+	if err := h.storage.Start(ctx); err != nil {
+		return err
+	}
+
+	if h.events != nil && h.events.Subscribed(EventConsensusClientSilent) && h.Cfg.Events.SilentAfter > 0 {
+		go h.watchSilence()
+	}
+
+	return nil
+}
+
+// Stop stops the handler. Calling it again is a no-op.
+func (h *Handler) Stop(ctx context.Context) error {
+	var err error
+
+	h.stopOnce.Do(func() {
+		close(h.done)
+
+		h.storage.Stop()
+
+		if h.slots != nil {
+			err = h.slots.Close()
+		}
+	})
+
+	return err
+}
+
+func (h *Handler) SubscribeNewHeads() (<-chan *ResultHeader, func()) {
+	return h.heads.Subscribe()
+}
+
+// Registry returns the methods of the handler, to add or override methods.
+func (h *Handler) Registry() *Registry {
+	return h.registry
+}
+
+// Storage returns the block storage backing the handler.
+func (h *Handler) Storage() *Storage {
+	return h.storage
+}
+
+func (h *Handler) Request(ctx context.Context, id int, method string, params []*json.RawMessage) (*Response, error) {
+	resp, err := h.handle(ctx, id, method, params)
+	if err == nil {
+		h.ObserveResponse(method, params, resp)
+	}
+
+	return resp, err
+}
+
+// Mirror stores the payload of a newPayload request, or moves the forkchoice of a forkchoiceUpdated request,
+// bypassing scripts, replay, method stubs and scenarios. Backends answering requests themselves use it to keep
+// the stub in sync, and pass their own responses to ObserveResponse.
+func (h *Handler) Mirror(method string, params []*json.RawMessage) error {
+	switch {
+	case strings.HasPrefix(method, "engine_newPayload"):
+		payload, err := decodePayload(params)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		resp.Result = result
-	case "eth_getBlockByHash":
-		result, err := h.getBlockByHash(params)
-		if err != nil && err != ErrUnsupportedGetBlockQuery {
-			return nil, err
+		h.storeBlock(payload, params)
+	case strings.HasPrefix(method, "engine_forkchoiceUpdated"):
+		forkchoiceState, err := decodeForkchoiceState(params)
+		if err != nil {
+			return err
 		}
 
-		resp.Result = result
-	case "eth_getBlockByNumber":
-		result, err := h.getBlockByNumber(params)
-		if err != nil && err != ErrUnsupportedGetBlockQuery {
-			return nil, err
+		h.setForkchoice(method, forkchoiceState, params)
+	}
+
+	return nil
+}
+
+// ObserveCall records the arrival of a request, for the slot timing and the last engine api call. Backends
+// answering requests themselves call it for every request.
+func (h *Handler) ObserveCall(method string, params []*json.RawMessage) {
+	now := time.Now()
+
+	if strings.HasPrefix(method, "engine_") {
+		atomic.StoreInt64(&h.lastEngineCall, now.UnixNano())
+	}
+
+	if h.slots != nil {
+		h.slots.Observe(now, method, params)
+	}
+}
+
+// LastEngineCall returns when the last engine_* request was received, or the zero time if none has been.
+func (h *Handler) LastEngineCall() time.Time {
+	nanos := atomic.LoadInt64(&h.lastEngineCall)
+	if nanos == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, nanos)
+}
+
+func (h *Handler) handle(ctx context.Context, id int, method string, params []*json.RawMessage) (*Response, error) {
+	h.ObserveCall(method, params)
+
+	return h.request(ctx, id, method, params)
+}
+
+func (h *Handler) request(ctx context.Context, id int, method string, params []*json.RawMessage) (*Response, error) {
+	if h.script != nil {
+		resp, ok, err := h.runScript(ctx, id, method, params)
+		if ok || err != nil {
+			return resp, err
 		}
+	}
 
-		resp.Result = result
-	case "eth_chainId":
-		resp.Result = ResultChainID(h.Cfg.ChainID)
-	case "engine_exchangeCapabilities":
-		if len(params) < 1 || params[0] == nil {
-			return nil, errors.New("missing params")
+	if h.replay != nil {
+		resp, ok, err := h.replay.Lookup(id, method, params)
+		if ok || err != nil {
+			return resp, err
 		}
+	}
 
-		var payload RequestParamsExchangeCapabilities
+	resp := &Response{
+		ID:      id,
+		JSONRPC: "2.0",
+		Result:  false,
+	}
 
-		err := json.Unmarshal([]byte(*params[0]), &payload)
-		if err != nil {
-			return nil, err
+	answered, err := h.answerMethod(method, params, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if answered {
+		return resp, nil
+	}
+
+	if err := h.call(ctx, method, params, resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// call answers a request with the method registered for it.
+func (h *Handler) call(ctx context.Context, method string, params []*json.RawMessage, resp *Response) error {
+	handler, ok := h.registry.Lookup(method)
+	if !ok {
+		h.metrics.ObserveUnknownMethod(method)
+		h.log.WithField("method", method).Warn("unsupported method")
+
+		if h.Cfg.UnknownMethods == UnknownMethodsError {
+			resp.Result = nil
+			resp.Error = unknownMethodError(method)
 		}
 
-		resp.Result = ResultexchangeCapabilities(payload)
-	case "eth_call":
+		return nil
+	}
+
+	result, err := handler(ctx, &MethodCall{Handler: h, Method: method, Params: params})
+
+	var rpcErr *ResponseError
+
+	switch {
+	case errors.As(err, &rpcErr):
+		resp.Result = nil
+		resp.Error = rpcErr
+	case err != nil:
+		return err
 	default:
-		h.log.WithField("method", method).Warn("unsupported method")
+		resp.Result = result
 	}
 
-	return resp, nil
+	return nil
 }
 
-func (h *Handler) forkChoiceUpdated(params []*json.RawMessage) (interface{}, error) {
-	if len(params) < 1 || params[0] == nil {
-		return nil, errors.New("missing params")
+// capabilities returns the engine api methods answered by the registry or configured methods.
+func (h *Handler) capabilities() []string {
+	seen := make(map[string]bool)
+	capabilities := []string{}
+
+	methods := h.registry.Methods()
+	for _, stub := range h.stubs {
+		methods = append(methods, stub.cfg.Method)
 	}
 
-	var forkchoiceState RequestParamsForkchoiceUpdatedV1
+	for _, method := range methods {
+		if !strings.HasPrefix(method, "engine_") || method == "engine_exchangeCapabilities" || seen[method] {
+			continue
+		}
+
+		seen[method] = true
+		capabilities = append(capabilities, method)
+	}
+
+	sort.Strings(capabilities)
+
+	return capabilities
+}
+
+// ConsensusClient returns the client version last reported by the consensus client, if any.
+func (h *Handler) ConsensusClient() *ClientVersionV1 {
+	h.consensusClientMu.Lock()
+	defer h.consensusClientMu.Unlock()
+
+	return h.consensusClient
+}
+
+func (h *Handler) getClientVersion(ctx context.Context, params RequestParamsGetClientVersionV1) ResultGetClientVersionV1 {
+	reported := ClientVersionV1(params)
+	client := ClientFromContext(ctx)
+
+	h.consensusClientMu.Lock()
+	defer h.consensusClientMu.Unlock()
+
+	previous, ok := h.consensusClients[client]
+	if !ok || previous.version != reported {
+		h.log.WithFields(logrus.Fields{
+			"client":  client,
+			"code":    reported.Code,
+			"name":    reported.Name,
+			"version": reported.Version,
+			"commit":  reported.Commit,
+		}).Info("consensus client reported version")
+	}
+
+	if !ok && len(h.consensusClients) >= maxConsensusClients {
+		h.forgetConsensusClient()
+	}
+
+	h.consensusClient = &reported
+	h.consensusClients[client] = &reportedVersion{version: reported, at: time.Now()}
+
+	h.metrics.ObserveConsensusClient(client, reported)
+
+	if ok && previous.version != reported {
+		h.metrics.DeleteConsensusClient(client, previous.version)
+	}
+
+	return ResultGetClientVersionV1{h.Cfg.ClientIdentity.clientVersion()}
+}
+
+// forgetConsensusClient forgets the version of the least recently reporting consensus client.
+func (h *Handler) forgetConsensusClient() {
+	oldest := ""
 
-	err := json.Unmarshal([]byte(*params[0]), &forkchoiceState)
+	for client, reported := range h.consensusClients {
+		if oldest == "" || reported.at.Before(h.consensusClients[oldest].at) {
+			oldest = client
+		}
+	}
+
+	h.metrics.DeleteConsensusClient(oldest, h.consensusClients[oldest].version)
+
+	delete(h.consensusClients, oldest)
+}
+
+func (h *Handler) forkChoiceUpdated(ctx context.Context, method string, params []*json.RawMessage) (interface{}, error) {
+	forkchoiceState, err := decodeForkchoiceState(params)
 	if err != nil {
 		return nil, err
 	}
 
+	if h.scenario != nil {
+		result, latency := h.scenario.ForkchoiceUpdated(method, forkchoiceState)
+		if err := scenarioDelay(ctx, latency); err != nil {
+			return nil, err
+		}
+
+		if result != nil {
+			h.metrics.ObserveForkchoiceUpdate(method, hasPayloadAttributes(params))
+
+			return result, nil
+		}
+	}
+
+	h.setForkchoice(method, forkchoiceState, params)
+
 	return ResultForkchoiceUpdatedV1{
 		PayloadStatus: ResultForkchoiceUpdatedV1PayloadStatus{
 			Status:          "VALID",
@@ -145,19 +414,33 @@ func (h *Handler) forkChoiceUpdated(params []*json.RawMessage) (interface{}, err
 	}, nil
 }
 
-func (h *Handler) newPayload(params []*json.RawMessage) (interface{}, error) {
-	if len(params) < 1 || params[0] == nil {
-		return nil, errors.New("missing params")
+func (h *Handler) newPayload(ctx context.Context, method string, params []*json.RawMessage) (interface{}, error) {
+	payload, err := decodePayload(params)
+	if err != nil {
+		return nil, err
 	}
 
-	var payload RequestParamsNewPayloadV1
+	var status *scenarioPayloadStatus
 
-	err := json.Unmarshal([]byte(*params[0]), &payload)
-	if err != nil {
-		return nil, err
+	if h.scenario != nil {
+		var latency time.Duration
+
+		status, latency = h.scenario.NewPayload(method, payload)
+		if err := scenarioDelay(ctx, latency); err != nil {
+			return nil, err
+		}
+
+		// Only ACCEPTED payloads are stored, the others were not imported.
+		if status != nil && status.Status != StatusAccepted {
+			return status, nil
+		}
 	}
 
-	h.storage.AddBlock(&payload, params[0])
+	h.storeBlock(payload, params)
+
+	if status != nil {
+		return status, nil
+	}
 
 	return ResultNewPayloadV1{
 		Status:          "VALID",
@@ -166,6 +449,108 @@ func (h *Handler) newPayload(params []*json.RawMessage) (interface{}, error) {
 	}, nil
 }
 
+func (h *Handler) setForkchoice(method string, forkchoiceState *RequestParamsForkchoiceUpdatedV1, params []*json.RawMessage) {
+	h.metrics.ObserveForkchoiceUpdate(method, hasPayloadAttributes(params))
+
+	previous := h.storage.SetForkchoice(*forkchoiceState)
+
+	h.observeForkchoice(previous, *forkchoiceState)
+
+	if previous.HeadBlockHash != forkchoiceState.HeadBlockHash {
+		if block := h.storage.GetBlockByHash(forkchoiceState.HeadBlockHash); block != nil {
+			h.heads.Publish(block.GetHeader())
+		}
+	}
+}
+
+func (h *Handler) storeBlock(payload *RequestParamsNewPayloadV1, params []*json.RawMessage) {
+	h.observeUnknownParent(payload)
+
+	h.storage.AddBlock(payload, params[0])
+
+	h.metrics.ObserveStoredBlocks(h.storage.Count())
+	h.metrics.ObservePayload(len(*params[0]), payload, blobCount(params))
+
+	// The forkchoice update may have arrived before the payload it points to.
+	if h.storage.GetForkchoice().HeadBlockHash == payload.BlockHash {
+		if block := h.storage.GetBlockByHash(payload.BlockHash); block != nil {
+			h.heads.Publish(block.GetHeader())
+		}
+	}
+}
+
+func decodeForkchoiceState(params []*json.RawMessage) (*RequestParamsForkchoiceUpdatedV1, error) {
+	if len(params) < 1 || params[0] == nil {
+		return nil, errors.New("missing params")
+	}
+
+	var forkchoiceState RequestParamsForkchoiceUpdatedV1
+	if err := json.Unmarshal(*params[0], &forkchoiceState); err != nil {
+		return nil, err
+	}
+
+	return &forkchoiceState, nil
+}
+
+func decodePayload(params []*json.RawMessage) (*RequestParamsNewPayloadV1, error) {
+	if len(params) < 1 || params[0] == nil {
+		return nil, errors.New("missing params")
+	}
+
+	var payload RequestParamsNewPayloadV1
+	if err := json.Unmarshal(*params[0], &payload); err != nil {
+		return nil, err
+	}
+
+	return &payload, nil
+}
+
+func hasPayloadAttributes(params []*json.RawMessage) bool {
+	return len(params) > 1 && params[1] != nil && string(*params[1]) != "null"
+}
+
+func (h *Handler) blockNumber() string {
+	block := h.storage.GetLatestBlock()
+	if block == nil {
+		return h.Cfg.TerminalBlockNumber
+	}
+
+	return fmt.Sprintf("0x%x", block.Number)
+}
+
+func (h *Handler) nodeInfo() ResultNodeInfo {
+	chainID, _ := parseHexBig(h.Cfg.ChainID)
+	network, _ := new(big.Int).SetString(h.Cfg.networkID(), 10)
+
+	head := h.Cfg.TerminalBlockHash
+	if block := h.storage.GetLatestBlock(); block != nil {
+		head = block.payload.BlockHash
+	}
+
+	return ResultNodeInfo{
+		ID:         nodeID,
+		Name:       h.Cfg.clientVersion(),
+		Enode:      fmt.Sprintf("enode://%s@127.0.0.1:30303", nodePubKey),
+		IP:         "127.0.0.1",
+		ListenAddr: "[::]:30303",
+		Ports: ResultNodeInfoPorts{
+			Discovery: 30303,
+			Listener:  30303,
+		},
+		Protocols: ResultNodeInfoProtocols{
+			Eth: ResultNodeInfoEth{
+				Network:    network,
+				Difficulty: h.Cfg.TerminalTotalDifficulty,
+				Head:       head,
+				Config: ResultNodeInfoChainConfig{
+					ChainID:                 chainID,
+					TerminalTotalDifficulty: h.Cfg.TerminalTotalDifficulty,
+				},
+			},
+		},
+	}
+}
+
 func (h *Handler) getBlockByHash(params []*json.RawMessage) (interface{}, error) {
 	if len(params) < 1 || params[0] == nil {
 		return nil, errors.New("missing params")
@@ -0,0 +1,92 @@
+package execution
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/creasty/defaults"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// newTestHandler returns a started handler with the default config, changed by configure if not nil.
+func newTestHandler(t *testing.T, configure func(*Config)) *Handler {
+	t.Helper()
+
+	conf := &Config{}
+	if err := defaults.Set(conf); err != nil {
+		t.Fatal(err)
+	}
+
+	if configure != nil {
+		configure(conf)
+	}
+
+	h, err := NewHandler(logrus.New(), conf, prometheus.NewRegistry(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = h.Stop(context.Background()) })
+
+	return h
+}
+
+func rawParams(t *testing.T, params ...interface{}) []*json.RawMessage {
+	t.Helper()
+
+	raws := make([]*json.RawMessage, len(params))
+
+	for i, param := range params {
+		data, err := json.Marshal(param)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		raw := json.RawMessage(data)
+		raws[i] = &raw
+	}
+
+	return raws
+}
+
+func testPayload(number, timestamp, hash, parent string) *RequestParamsNewPayloadV1 {
+	return &RequestParamsNewPayloadV1{
+		BlockNumber:   number,
+		Timestamp:     timestamp,
+		BlockHash:     hash,
+		ParentHash:    parent,
+		FeeRecipient:  "0x0000000000000000000000000000000000000000",
+		StateRoot:     "0x00",
+		ReceiptsRoot:  "0x00",
+		LogsBloom:     "0x00",
+		Random:        "0x00",
+		GasLimit:      "0x1c9c380",
+		GasUsed:       "0x0",
+		ExtraData:     "0x",
+		BaseFeePerGas: "0x7",
+		Transactions:  []string{},
+	}
+}
+
+// encode returns the JSON encoding of a response's result or error, to compare it independent of its Go type.
+func encode(t *testing.T, resp *Response) string {
+	t.Helper()
+
+	var v interface{} = resp.Result
+	if resp.Error != nil {
+		v = resp.Error
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
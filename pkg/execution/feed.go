@@ -0,0 +1,50 @@
+package execution
+
+import "sync"
+
+// headFeed fans out new head headers to subscribers. Slow subscribers miss headers rather than block the handler.
+type headFeed struct {
+	subs map[int]chan *ResultHeader
+	next int
+
+	mu sync.Mutex
+}
+
+func newHeadFeed() *headFeed {
+	return &headFeed{
+		subs: make(map[int]chan *ResultHeader),
+	}
+}
+
+func (f *headFeed) Subscribe() (<-chan *ResultHeader, func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := f.next
+	f.next++
+
+	ch := make(chan *ResultHeader, 16)
+	f.subs[id] = ch
+
+	return ch, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		if _, exists := f.subs[id]; exists {
+			delete(f.subs, id)
+			close(ch)
+		}
+	}
+}
+
+func (f *headFeed) Publish(header *ResultHeader) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, ch := range f.subs {
+		select {
+		case ch <- header:
+		default:
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package execution
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"text/template"
+
+	"github.com/creasty/defaults"
+)
+
+const (
+	// UnknownMethodsFalse answers methods stubbies doesn't know with a false result.
+	UnknownMethodsFalse = "false"
+	// UnknownMethodsError answers methods stubbies doesn't know with a -32601 method not found error.
+	UnknownMethodsError = "error"
+)
+
+// MethodConfig answers a method with a templated result or a fixed error.
+type MethodConfig struct {
+	Method string `yaml:"method"`
+	// Params optionally restricts the stub to requests whose params match these regular expressions, by position.
+	// String params are matched by their value, others by their JSON encoding. Empty expressions match anything.
+	Params []string `yaml:"params"`
+	// Result is a Go template rendering the JSON result, e.g. '"{{ .Head.Number }}"'. It has access to
+	// .Method, .Params (decoded JSON), .ChainID and .Head (the forkchoice head, or highest stored block).
+	Result string `yaml:"result"`
+	// Error answers with this error instead of a result.
+	Error *MethodErrorConfig `yaml:"error"`
+}
+
+type MethodErrorConfig struct {
+	Code    int    `yaml:"code" default:"-32000"`
+	Message string `yaml:"message"`
+	// Data is optional raw JSON returned as the error data.
+	Data string `yaml:"data"`
+}
+
+// UnmarshalYAML applies the defaults, they are not set for pointers otherwise.
+func (c *MethodErrorConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := defaults.Set(c); err != nil {
+		return err
+	}
+
+	type plain MethodErrorConfig
+
+	return unmarshal((*plain)(c))
+}
+
+func (c *MethodConfig) Validate() error {
+	_, err := newMethodStub(c)
+
+	return err
+}
+
+// methodData is the data available to result templates.
+type methodData struct {
+	Method  string
+	Params  []interface{}
+	ChainID string
+	Head    ResultGetBlock
+}
+
+type methodStub struct {
+	cfg    MethodConfig
+	params []*regexp.Regexp
+	result *template.Template
+}
+
+var methodFuncs = template.FuncMap{
+	// toJSON encodes a value as JSON, e.g. to return a param unchanged.
+	"toJSON": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+
+		return string(data), err
+	},
+	// fromHex decodes a 0x prefixed hex quantity.
+	"fromHex": func(s string) (*big.Int, error) {
+		n, ok := parseHexBig(s)
+		if !ok {
+			return nil, fmt.Errorf("invalid hex quantity %q", s)
+		}
+
+		return n, nil
+	},
+	// toHex encodes an integer as a 0x prefixed hex quantity.
+	"toHex": func(v interface{}) (string, error) {
+		switch n := v.(type) {
+		case *big.Int:
+			return "0x" + n.Text(16), nil
+		case int:
+			return fmt.Sprintf("0x%x", n), nil
+		case int64:
+			return fmt.Sprintf("0x%x", n), nil
+		case uint64:
+			return fmt.Sprintf("0x%x", n), nil
+		case float64:
+			return fmt.Sprintf("0x%x", int64(n)), nil
+		}
+
+		return "", fmt.Errorf("can't hex encode %T", v)
+	},
+	// add adds two integers, e.g. {{ toHex (add (fromHex .Head.Number) 1) }}.
+	"add": func(a, b interface{}) (*big.Int, error) {
+		x, err := toBig(a)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := toBig(b)
+		if err != nil {
+			return nil, err
+		}
+
+		return new(big.Int).Add(x, y), nil
+	},
+}
+
+func toBig(v interface{}) (*big.Int, error) {
+	switch n := v.(type) {
+	case *big.Int:
+		return n, nil
+	case int:
+		return big.NewInt(int64(n)), nil
+	case float64:
+		return big.NewInt(int64(n)), nil
+	case string:
+		if parsed, ok := parseHexBig(n); ok {
+			return parsed, nil
+		}
+	}
+
+	return nil, fmt.Errorf("not an integer: %v", v)
+}
+
+func newMethodStub(conf *MethodConfig) (*methodStub, error) {
+	if conf.Method == "" {
+		return nil, errors.New("methods: method is required")
+	}
+
+	if (conf.Result == "") == (conf.Error == nil) {
+		return nil, fmt.Errorf("methods: %s needs exactly one of result and error", conf.Method)
+	}
+
+	if conf.Error != nil && conf.Error.Data != "" && !json.Valid([]byte(conf.Error.Data)) {
+		return nil, fmt.Errorf("methods: %s: error data must be valid JSON", conf.Method)
+	}
+
+	stub := &methodStub{cfg: *conf}
+
+	for _, expr := range conf.Params {
+		if expr == "" {
+			stub.params = append(stub.params, nil)
+
+			continue
+		}
+
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("methods: %s: invalid params expression: %w", conf.Method, err)
+		}
+
+		stub.params = append(stub.params, re)
+	}
+
+	if conf.Result != "" {
+		tmpl, err := template.New(conf.Method).Funcs(methodFuncs).Option("missingkey=error").Parse(conf.Result)
+		if err != nil {
+			return nil, fmt.Errorf("methods: %s: invalid result template: %w", conf.Method, err)
+		}
+
+		stub.result = tmpl
+	}
+
+	return stub, nil
+}
+
+func (s *methodStub) matches(method string, params []*json.RawMessage) bool {
+	if s.cfg.Method != method {
+		return false
+	}
+
+	for i, re := range s.params {
+		if re == nil {
+			continue
+		}
+
+		if i >= len(params) || params[i] == nil {
+			return false
+		}
+
+		value := string(*params[i])
+
+		var str string
+		if err := json.Unmarshal(*params[i], &str); err == nil {
+			value = str
+		}
+
+		if !re.MatchString(value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *methodStub) answer(resp *Response, data *methodData) error {
+	if s.cfg.Error != nil {
+		resp.Error = &ResponseError{
+			Code:    s.cfg.Error.Code,
+			Message: s.cfg.Error.Message,
+		}
+
+		if s.cfg.Error.Data != "" {
+			resp.Error.Data = json.RawMessage(s.cfg.Error.Data)
+		}
+
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := s.result.Execute(&buf, data); err != nil {
+		return fmt.Errorf("failed to render result of %s: %w", s.cfg.Method, err)
+	}
+
+	result := bytes.TrimSpace(buf.Bytes())
+	if !json.Valid(result) {
+		return fmt.Errorf("result template of %s rendered invalid JSON: %s", s.cfg.Method, result)
+	}
+
+	resp.Result = json.RawMessage(result)
+
+	return nil
+}
+
+// answerMethod answers a request with the first matching method stub. ok is false when none matches.
+func (h *Handler) answerMethod(method string, params []*json.RawMessage, resp *Response) (ok bool, err error) {
+	for _, stub := range h.stubs {
+		if !stub.matches(method, params) {
+			continue
+		}
+
+		data := &methodData{
+			Method:  method,
+			ChainID: h.Cfg.ChainID,
+		}
+
+		for _, param := range params {
+			var value interface{}
+
+			if param != nil {
+				if err := json.Unmarshal(*param, &value); err != nil {
+					return false, err
+				}
+			}
+
+			data.Params = append(data.Params, value)
+		}
+
+		head := h.storage.GetBlockByHash(h.storage.GetForkchoice().HeadBlockHash)
+		if head == nil {
+			head = h.storage.GetLatestBlock()
+		}
+
+		if head != nil {
+			if result := head.GetResult(); result != nil {
+				data.Head = *result
+			}
+		}
+
+		return true, stub.answer(resp, data)
+	}
+
+	return false, nil
+}
+
+func unknownMethodError(method string) *ResponseError {
+	return &ResponseError{
+		Code:    ErrorCodeMethodNotFound,
+		Message: fmt.Sprintf("the method %s does not exist/is not available", method),
+	}
+}
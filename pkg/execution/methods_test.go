@@ -0,0 +1,118 @@
+package execution
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestMethodConfigValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		conf MethodConfig
+		err  bool
+	}{
+		{name: "result", conf: MethodConfig{Method: "eth_gasPrice", Result: `"0x1"`}},
+		{name: "error", conf: MethodConfig{Method: "eth_gasPrice", Error: &MethodErrorConfig{Code: -32000, Message: "failed", Data: `{"a":1}`}}},
+		{name: "no method", conf: MethodConfig{Result: `"0x1"`}, err: true},
+		{name: "no result or error", conf: MethodConfig{Method: "eth_gasPrice"}, err: true},
+		{name: "result and error", conf: MethodConfig{Method: "eth_gasPrice", Result: `"0x1"`, Error: &MethodErrorConfig{}}, err: true},
+		{name: "invalid error data", conf: MethodConfig{Method: "eth_gasPrice", Error: &MethodErrorConfig{Data: `{`}}, err: true},
+		{name: "invalid params expression", conf: MethodConfig{Method: "eth_gasPrice", Params: []string{"("}, Result: `"0x1"`}, err: true},
+		{name: "invalid template", conf: MethodConfig{Method: "eth_gasPrice", Result: `{{ .Head`}, err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.conf.Validate(); (err != nil) != test.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestHandlerMethodStubs(t *testing.T) {
+	h := newTestHandler(t, func(conf *Config) {
+		conf.ChainID = "0x5"
+		conf.UnknownMethods = UnknownMethodsError
+		conf.Methods = []MethodConfig{
+			{Method: "eth_getBalance", Params: []string{"0x0{40}"}, Result: `"0x0"`},
+			{Method: "eth_getBalance", Params: []string{"", "latest"}, Result: `"0xde0b6b3a7640000"`},
+			{Method: "eth_getBalance", Error: &MethodErrorConfig{Code: -32000, Message: "historical state unavailable", Data: `{"block":"pruned"}`}},
+			{Method: "eth_gasPrice", Result: `"{{ toHex (add (fromHex .Head.Number) 1) }}"`},
+			{Method: "eth_echo", Result: `{"method":"{{ .Method }}","params":{{ toJSON .Params }},"chainId":"{{ .ChainID }}"}`},
+			{Method: "eth_estimateGas", Params: []string{`\{.*"to":"0x1+".*\}`}, Result: `"0x5208"`},
+			{Method: "eth_missing", Result: `"{{ .Missing }}"`},
+			{Method: "eth_invalid", Result: `{"unterminated":`},
+		}
+	})
+
+	for _, payload := range []*RequestParamsNewPayloadV1{testPayload("0x1", "0x3f4", "0x01", "0x00"), testPayload("0x2", "0x400", "0x02", "0x01")} {
+		if _, err := h.Request(context.Background(), 1, "engine_newPayloadV1", rawParams(t, payload)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		params   []interface{}
+		expected string
+		err      bool
+	}{
+		{name: "first matching params", method: "eth_getBalance", params: []interface{}{"0x0000000000000000000000000000000000000000", "latest"}, expected: `"0x0"`},
+		{name: "any first param", method: "eth_getBalance", params: []interface{}{"0x01", "latest"}, expected: `"0xde0b6b3a7640000"`},
+		{name: "error", method: "eth_getBalance", params: []interface{}{"0x01", "0x1"}, expected: `{"code":-32000,"message":"historical state unavailable","data":{"block":"pruned"}}`},
+		{name: "missing param", method: "eth_getBalance", params: []interface{}{"0x01"}, expected: `{"code":-32000,"message":"historical state unavailable","data":{"block":"pruned"}}`},
+		{name: "head template", method: "eth_gasPrice", expected: `"0x3"`},
+		{name: "request template", method: "eth_echo", params: []interface{}{1, "a"}, expected: `{"method":"eth_echo","params":[1,"a"],"chainId":"0x5"}`},
+		{name: "object params", method: "eth_estimateGas", params: []interface{}{map[string]string{"to": "0x11"}}, expected: `"0x5208"`},
+		{name: "unmatched object params", method: "eth_estimateGas", params: []interface{}{map[string]string{"to": "0x12"}}, expected: `{"code":-32601,"message":"the method eth_estimateGas does not exist/is not available"}`},
+		{name: "built-in", method: "eth_chainId", expected: `"0x5"`},
+		{name: "unknown", method: "eth_unknown", expected: `{"code":-32601,"message":"the method eth_unknown does not exist/is not available"}`},
+		{name: "missing template key", method: "eth_missing", err: true},
+		{name: "invalid json", method: "eth_invalid", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := h.Request(context.Background(), 1, test.method, rawParams(t, test.params...))
+			if (err != nil) != test.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if test.err {
+				return
+			}
+
+			if got := encode(t, resp); got != test.expected {
+				t.Fatalf("answered %s, expected %s", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestResponseMarshalJSON(t *testing.T) {
+	tests := []struct {
+		resp     Response
+		expected string
+	}{
+		{resp: Response{ID: 1, JSONRPC: "2.0", Result: false}, expected: `{"id":1,"jsonrpc":"2.0","result":false}`},
+		{resp: Response{ID: 1, JSONRPC: "2.0", Result: nil}, expected: `{"id":1,"jsonrpc":"2.0","result":null}`},
+		{
+			resp:     Response{ID: 2, JSONRPC: "2.0", Result: false, Error: unknownMethodError("eth_unknown")},
+			expected: `{"id":2,"jsonrpc":"2.0","error":{"code":-32601,"message":"the method eth_unknown does not exist/is not available"}}`,
+		},
+	}
+
+	for _, test := range tests {
+		data, err := json.Marshal(test.resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != test.expected {
+			t.Errorf("encoded %s, expected %s", data, test.expected)
+		}
+	}
+}
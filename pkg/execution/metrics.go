@@ -0,0 +1,233 @@
+package execution
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var knownNamespaceMethod = regexp.MustCompile(`^(engine|eth|net|web3)_[a-zA-Z0-9]{1,64}$`)
+
+type Metrics struct {
+	consensusClientInfo *prometheus.GaugeVec
+
+	blockNumber    *prometheus.GaugeVec
+	blockTimestamp *prometheus.GaugeVec
+	storedBlocks   prometheus.Gauge
+
+	payloads          *prometheus.CounterVec
+	forkchoiceUpdates *prometheus.CounterVec
+	reorgs            prometheus.Counter
+	reorgDepth        prometheus.Histogram
+	unknownMethods    *prometheus.CounterVec
+
+	payloadSize      prometheus.Histogram
+	payloadTxCount   prometheus.Histogram
+	payloadGasUsed   prometheus.Histogram
+	payloadBlobCount prometheus.Histogram
+
+	slotCallOffset  *prometheus.HistogramVec
+	newPayloadDelay *prometheus.HistogramVec
+	blockBuildTime  prometheus.Histogram
+	blockImportTime prometheus.Histogram
+}
+
+func NewMetrics(namespace string, reg prometheus.Registerer) Metrics {
+	m := Metrics{
+		consensusClientInfo: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "consensus_client_info",
+			Help:      "Client version reported by each consensus client via engine_getClientVersionV1",
+		}, []string{"client", "code", "name", "version", "commit"}),
+		blockNumber: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "block_number",
+			Help:      "Block number of the head, safe and finalized blocks of the latest forkchoice update",
+		}, []string{"block"}),
+		blockTimestamp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "block_timestamp_seconds",
+			Help:      "Timestamp of the head, safe and finalized blocks of the latest forkchoice update",
+		}, []string{"block"}),
+		storedBlocks: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "stored_blocks",
+			Help:      "Number of blocks held in storage",
+		}),
+		payloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "payloads_total",
+			Help:      "Number of payloads received, by returned status",
+		}, []string{"method", "status"}),
+		forkchoiceUpdates: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "forkchoice_updates_total",
+			Help:      "Number of forkchoice updates received, by whether they carried payload attributes",
+		}, []string{"method", "with_attributes"}),
+		reorgs: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "reorgs_total",
+			Help:      "Number of forkchoice updates moving the head to a block that does not build on the previous head",
+		}),
+		reorgDepth: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "reorg_depth",
+			Help:      "Number of blocks removed from the canonical chain by a reorg",
+			Buckets:   []float64{1, 2, 3, 4, 8, 16, 32, 64},
+		}),
+		unknownMethods: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "unknown_methods_total",
+			Help:      "Number of requests for methods stubbies does not implement, methods outside the engine, eth, net and web3 namespaces are counted as other",
+		}, []string{"method"}),
+		payloadSize: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "payload_size_bytes",
+			Help:      "Size of received payloads",
+			Buckets:   prometheus.ExponentialBuckets(1024, 4, 9),
+		}),
+		payloadTxCount: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "payload_transactions",
+			Help:      "Number of transactions in received payloads",
+			Buckets:   []float64{0, 10, 50, 100, 200, 400, 800, 1600},
+		}),
+		payloadGasUsed: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "payload_gas_used",
+			Help:      "Gas used by received payloads",
+			Buckets:   prometheus.LinearBuckets(0, 3_000_000, 11),
+		}),
+		payloadBlobCount: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "payload_blobs",
+			Help:      "Number of blobs referenced by received payloads",
+			Buckets:   prometheus.LinearBuckets(0, 1, 10),
+		}),
+		slotCallOffset: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "slot_call_offset_seconds",
+			Help:      "Time into the slot engine api calls arrived at",
+			Buckets:   prometheus.LinearBuckets(-4, 1, 17),
+		}, []string{"method"}),
+		newPayloadDelay: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "slot_new_payload_delay_seconds",
+			Help:      "Time between the payload timestamp and the arrival of the newPayload call",
+			Buckets:   []float64{0.5, 1, 1.5, 2, 2.5, 3, 3.5, 4, 5, 6, 8, 12},
+		}, []string{"method"}),
+		blockBuildTime: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "slot_block_build_seconds",
+			Help:      "Time between a forkchoice update with payload attributes and the getPayload call for it",
+			Buckets:   []float64{0.5, 1, 2, 3, 4, 6, 8, 12},
+		}),
+		blockImportTime: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "slot_block_import_seconds",
+			Help:      "Time between a newPayload call and the forkchoice update making the payload head",
+			Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 4, 8},
+		}),
+	}
+
+	reg.MustRegister(m.consensusClientInfo)
+	reg.MustRegister(m.blockNumber)
+	reg.MustRegister(m.blockTimestamp)
+	reg.MustRegister(m.storedBlocks)
+	reg.MustRegister(m.payloads)
+	reg.MustRegister(m.forkchoiceUpdates)
+	reg.MustRegister(m.reorgs)
+	reg.MustRegister(m.reorgDepth)
+	reg.MustRegister(m.unknownMethods)
+	reg.MustRegister(m.payloadSize)
+	reg.MustRegister(m.payloadTxCount)
+	reg.MustRegister(m.payloadGasUsed)
+	reg.MustRegister(m.payloadBlobCount)
+	reg.MustRegister(m.slotCallOffset)
+	reg.MustRegister(m.newPayloadDelay)
+	reg.MustRegister(m.blockBuildTime)
+	reg.MustRegister(m.blockImportTime)
+
+	return m
+}
+
+// ObserveConsensusClient records the version reported by a consensus client.
+func (m Metrics) ObserveConsensusClient(client string, v ClientVersionV1) {
+	m.consensusClientInfo.WithLabelValues(client, v.Code, v.Name, v.Version, v.Commit).Set(1)
+}
+
+// DeleteConsensusClient removes a version previously reported by a consensus client.
+func (m Metrics) DeleteConsensusClient(client string, v ClientVersionV1) {
+	m.consensusClientInfo.DeleteLabelValues(client, v.Code, v.Name, v.Version, v.Commit)
+}
+
+// ObserveForkchoiceBlock records the number and timestamp of the head, safe or finalized block.
+func (m Metrics) ObserveForkchoiceBlock(kind string, block *Block) {
+	m.blockNumber.WithLabelValues(kind).Set(float64(block.Number.Uint64()))
+
+	if timestamp, ok := parseHexBig(block.payload.Timestamp); ok {
+		m.blockTimestamp.WithLabelValues(kind).Set(float64(timestamp.Uint64()))
+	}
+}
+
+func (m Metrics) ObserveStoredBlocks(count int) {
+	m.storedBlocks.Set(float64(count))
+}
+
+func (m Metrics) ObservePayloadStatus(method, status string) {
+	m.payloads.WithLabelValues(method, status).Inc()
+}
+
+func (m Metrics) ObservePayload(size int, payload *RequestParamsNewPayloadV1, blobs int) {
+	m.payloadSize.Observe(float64(size))
+	m.payloadTxCount.Observe(float64(len(payload.Transactions)))
+	m.payloadBlobCount.Observe(float64(blobs))
+
+	if gasUsed, ok := parseHexBig(payload.GasUsed); ok {
+		m.payloadGasUsed.Observe(float64(gasUsed.Uint64()))
+	}
+}
+
+func (m Metrics) ObserveForkchoiceUpdate(method string, withAttributes bool) {
+	attributes := "false"
+	if withAttributes {
+		attributes = "true"
+	}
+
+	m.forkchoiceUpdates.WithLabelValues(method, attributes).Inc()
+}
+
+func (m Metrics) ObserveReorg(depth int) {
+	m.reorgs.Inc()
+
+	if depth > 0 {
+		m.reorgDepth.Observe(float64(depth))
+	}
+}
+
+// ObserveUnknownMethod counts a request for a method stubbies doesn't know. The method comes from the caller, so
+// only well formed methods of the known namespaces get their own series.
+func (m Metrics) ObserveUnknownMethod(method string) {
+	if !knownNamespaceMethod.MatchString(method) {
+		method = "other"
+	}
+
+	m.unknownMethods.WithLabelValues(method).Inc()
+}
+
+func (m Metrics) ObserveSlotCallOffset(method string, offset time.Duration) {
+	m.slotCallOffset.WithLabelValues(method).Observe(offset.Seconds())
+}
+
+func (m Metrics) ObserveNewPayloadDelay(method string, delay time.Duration) {
+	m.newPayloadDelay.WithLabelValues(method).Observe(delay.Seconds())
+}
+
+func (m Metrics) ObserveBlockBuild(duration time.Duration) {
+	m.blockBuildTime.Observe(duration.Seconds())
+}
+
+func (m Metrics) ObserveBlockImport(duration time.Duration) {
+	m.blockImportTime.Observe(duration.Seconds())
+}
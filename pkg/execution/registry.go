@@ -0,0 +1,173 @@
+package execution
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// MethodCall is a single call of a registered method.
+type MethodCall struct {
+	Handler *Handler
+	Method  string
+	Params  []*json.RawMessage
+}
+
+// MethodHandler answers a method with its result. Returning a *ResponseError answers with that JSON-RPC
+// error, any other error fails the request.
+type MethodHandler func(ctx context.Context, call *MethodCall) (interface{}, error)
+
+// TypedMethod returns a MethodHandler decoding the first param into P before calling fn.
+func TypedMethod[P any](fn func(ctx context.Context, call *MethodCall, params P) (interface{}, error)) MethodHandler {
+	return func(ctx context.Context, call *MethodCall) (interface{}, error) {
+		var params P
+
+		if len(call.Params) < 1 || call.Params[0] == nil {
+			return nil, errors.New("missing params")
+		}
+
+		if err := json.Unmarshal(*call.Params[0], &params); err != nil {
+			return nil, err
+		}
+
+		return fn(ctx, call, params)
+	}
+}
+
+// Registry maps method names to their handlers. It is safe for concurrent use, methods can be
+// registered or replaced while requests are served.
+type Registry struct {
+	methods map[string]MethodHandler
+
+	mu sync.RWMutex
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		methods: make(map[string]MethodHandler),
+	}
+}
+
+// DefaultRegistry returns a registry with the methods stubbies implements.
+func DefaultRegistry() *Registry {
+	r := NewRegistry()
+
+	constant := func(result interface{}) MethodHandler {
+		return func(context.Context, *MethodCall) (interface{}, error) {
+			return result, nil
+		}
+	}
+
+	forkchoiceUpdated := func(ctx context.Context, call *MethodCall) (interface{}, error) {
+		return call.Handler.forkChoiceUpdated(ctx, call.Method, call.Params)
+	}
+
+	newPayload := func(ctx context.Context, call *MethodCall) (interface{}, error) {
+		return call.Handler.newPayload(ctx, call.Method, call.Params)
+	}
+
+	r.Register("engine_exchangeTransitionConfigurationV1", func(_ context.Context, call *MethodCall) (interface{}, error) {
+		return ResultExchangeTransitionConfigurationV1{
+			TerminalTotalDifficulty: call.Handler.Cfg.TerminalTotalDifficulty,
+			TerminalBlockHash:       call.Handler.Cfg.TerminalBlockHash,
+			TerminalBlockNumber:     call.Handler.Cfg.TerminalBlockNumber,
+		}, nil
+	})
+	r.Register("engine_forkchoiceUpdatedV1", forkchoiceUpdated)
+	r.Register("engine_forkchoiceUpdatedV2", forkchoiceUpdated)
+	r.Register("engine_newPayloadV1", newPayload)
+	r.Register("engine_newPayloadV2", newPayload)
+	r.Register("engine_newPayloadV3", newPayload)
+	r.Register("engine_exchangeCapabilities", TypedMethod(func(_ context.Context, call *MethodCall, _ RequestParamsExchangeCapabilities) (interface{}, error) {
+		return ResultexchangeCapabilities(call.Handler.capabilities()), nil
+	}))
+	r.Register("engine_getClientVersionV1", TypedMethod(func(ctx context.Context, call *MethodCall, params RequestParamsGetClientVersionV1) (interface{}, error) {
+		return call.Handler.getClientVersion(ctx, params), nil
+	}))
+
+	r.Register("eth_syncing", constant(false))
+	r.Register("eth_getBlockByHash", func(_ context.Context, call *MethodCall) (interface{}, error) {
+		result, err := call.Handler.getBlockByHash(call.Params)
+		if err != nil && err != ErrUnsupportedGetBlockQuery {
+			return nil, err
+		}
+
+		return result, nil
+	})
+	r.Register("eth_getBlockByNumber", func(_ context.Context, call *MethodCall) (interface{}, error) {
+		result, err := call.Handler.getBlockByNumber(call.Params)
+		if err != nil && err != ErrUnsupportedGetBlockQuery {
+			return nil, err
+		}
+
+		return result, nil
+	})
+	r.Register("eth_chainId", func(_ context.Context, call *MethodCall) (interface{}, error) {
+		return ResultChainID(call.Handler.Cfg.ChainID), nil
+	})
+	r.Register("eth_blockNumber", func(_ context.Context, call *MethodCall) (interface{}, error) {
+		return call.Handler.blockNumber(), nil
+	})
+	r.Register("eth_getBalance", constant(ResultGetBalance("0x0")))
+	r.Register("eth_call", constant(false))
+
+	r.Register("net_version", func(_ context.Context, call *MethodCall) (interface{}, error) {
+		return call.Handler.Cfg.networkID(), nil
+	})
+	r.Register("net_listening", constant(true))
+	r.Register("net_peerCount", func(_ context.Context, call *MethodCall) (interface{}, error) {
+		return call.Handler.Cfg.PeerCount, nil
+	})
+	r.Register("web3_clientVersion", func(_ context.Context, call *MethodCall) (interface{}, error) {
+		return call.Handler.Cfg.clientVersion(), nil
+	})
+	r.Register("admin_nodeInfo", func(_ context.Context, call *MethodCall) (interface{}, error) {
+		return call.Handler.nodeInfo(), nil
+	})
+
+	return r
+}
+
+// Register adds a method, replacing any handler already registered for it.
+func (r *Registry) Register(method string, handler MethodHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.methods[method] = handler
+}
+
+// Unregister removes a method.
+func (r *Registry) Unregister(method string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.methods, method)
+}
+
+// Lookup returns the handler of a method.
+func (r *Registry) Lookup(method string) (MethodHandler, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	handler, ok := r.methods[method]
+
+	return handler, ok
+}
+
+// Methods returns the sorted names of all registered methods.
+func (r *Registry) Methods() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	methods := make([]string, 0, len(r.methods))
+	for method := range r.methods {
+		methods = append(methods, method)
+	}
+
+	sort.Strings(methods)
+
+	return methods
+}
@@ -0,0 +1,219 @@
+package execution
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+
+	r.Register("b_method", nil)
+	r.Register("a_method", nil)
+	r.Register("c_method", nil)
+	r.Unregister("c_method")
+
+	if methods := r.Methods(); !reflect.DeepEqual(methods, []string{"a_method", "b_method"}) {
+		t.Fatalf("registered methods are %v", methods)
+	}
+
+	if _, ok := r.Lookup("c_method"); ok {
+		t.Fatal("unregistered method was found")
+	}
+}
+
+func TestHandlerCall(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		params   []interface{}
+		handler  MethodHandler
+		unknown  string
+		expected string
+		err      bool
+	}{
+		{name: "built-in", method: "eth_chainId", expected: `"0x1"`},
+		{name: "net_version", method: "net_version", expected: `"1"`},
+		{
+			name:   "registered",
+			method: "eth_gasPrice",
+			handler: func(_ context.Context, call *MethodCall) (interface{}, error) {
+				return call.Method, nil
+			},
+			expected: `"eth_gasPrice"`,
+		},
+		{
+			name:   "overridden",
+			method: "eth_chainId",
+			handler: func(context.Context, *MethodCall) (interface{}, error) {
+				return "0x5", nil
+			},
+			expected: `"0x5"`,
+		},
+		{
+			name:   "json-rpc error",
+			method: "eth_gasPrice",
+			handler: func(context.Context, *MethodCall) (interface{}, error) {
+				return nil, &ResponseError{Code: ErrorCodeServerError, Message: "no gas"}
+			},
+			expected: `{"code":-32000,"message":"no gas"}`,
+		},
+		{
+			name:   "failure",
+			method: "eth_gasPrice",
+			handler: func(context.Context, *MethodCall) (interface{}, error) {
+				return nil, errors.New("failed")
+			},
+			err: true,
+		},
+		{
+			name:   "typed params",
+			method: "eth_echo",
+			params: []interface{}{map[string]string{"value": "0x2a"}},
+			handler: TypedMethod(func(_ context.Context, _ *MethodCall, params struct{ Value string }) (interface{}, error) {
+				return params.Value, nil
+			}),
+			expected: `"0x2a"`,
+		},
+		{
+			name:   "typed params missing",
+			method: "eth_echo",
+			handler: TypedMethod(func(_ context.Context, _ *MethodCall, params struct{ Value string }) (interface{}, error) {
+				return params.Value, nil
+			}),
+			err: true,
+		},
+		{name: "unknown", method: "eth_unknown", unknown: UnknownMethodsFalse, expected: `false`},
+		{
+			name:     "unknown error",
+			method:   "eth_unknown",
+			unknown:  UnknownMethodsError,
+			expected: `{"code":-32601,"message":"the method eth_unknown does not exist/is not available"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := newTestHandler(t, func(conf *Config) {
+				if test.unknown != "" {
+					conf.UnknownMethods = test.unknown
+				}
+			})
+
+			if test.handler != nil {
+				h.Registry().Register(test.method, test.handler)
+			}
+
+			resp, err := h.Request(context.Background(), 1, test.method, rawParams(t, test.params...))
+			if (err != nil) != test.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if test.err {
+				return
+			}
+
+			if got := encode(t, resp); got != test.expected {
+				t.Fatalf("answered %s, expected %s", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestExchangeCapabilities(t *testing.T) {
+	builtin := []string{
+		"engine_exchangeTransitionConfigurationV1",
+		"engine_forkchoiceUpdatedV1",
+		"engine_forkchoiceUpdatedV2",
+		"engine_getClientVersionV1",
+		"engine_newPayloadV1",
+		"engine_newPayloadV2",
+		"engine_newPayloadV3",
+	}
+
+	tests := []struct {
+		name       string
+		methods    []MethodConfig
+		register   []string
+		unregister []string
+		expected   []string
+	}{
+		{name: "built-in methods", expected: builtin},
+		{
+			name:     "registered methods",
+			register: []string{"engine_getPayloadV1", "eth_gasPrice"},
+			expected: []string{
+				"engine_exchangeTransitionConfigurationV1",
+				"engine_forkchoiceUpdatedV1",
+				"engine_forkchoiceUpdatedV2",
+				"engine_getClientVersionV1",
+				"engine_getPayloadV1",
+				"engine_newPayloadV1",
+				"engine_newPayloadV2",
+				"engine_newPayloadV3",
+			},
+		},
+		{
+			name:       "unregistered methods",
+			unregister: []string{"engine_exchangeTransitionConfigurationV1", "engine_newPayloadV3"},
+			expected: []string{
+				"engine_forkchoiceUpdatedV1",
+				"engine_forkchoiceUpdatedV2",
+				"engine_getClientVersionV1",
+				"engine_newPayloadV1",
+				"engine_newPayloadV2",
+			},
+		},
+		{
+			name: "configured methods",
+			methods: []MethodConfig{
+				{Method: "engine_getPayloadBodiesByHashV1", Result: "[]"},
+				{Method: "engine_newPayloadV1", Result: `{"status":"SYNCING"}`},
+			},
+			expected: []string{
+				"engine_exchangeTransitionConfigurationV1",
+				"engine_forkchoiceUpdatedV1",
+				"engine_forkchoiceUpdatedV2",
+				"engine_getClientVersionV1",
+				"engine_getPayloadBodiesByHashV1",
+				"engine_newPayloadV1",
+				"engine_newPayloadV2",
+				"engine_newPayloadV3",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := newTestHandler(t, func(conf *Config) {
+				conf.Methods = test.methods
+			})
+
+			for _, method := range test.register {
+				h.Registry().Register(method, func(context.Context, *MethodCall) (interface{}, error) {
+					return nil, nil
+				})
+			}
+
+			for _, method := range test.unregister {
+				h.Registry().Unregister(method)
+			}
+
+			resp, err := h.Request(context.Background(), 1, "engine_exchangeCapabilities", rawParams(t, []string{"engine_newPayloadV1"}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			capabilities, ok := resp.Result.(ResultexchangeCapabilities)
+			if !ok {
+				t.Fatalf("answered %T, expected capabilities", resp.Result)
+			}
+
+			if !reflect.DeepEqual([]string(capabilities), test.expected) {
+				t.Fatalf("capabilities are %v, expected %v", capabilities, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package execution
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethpandaops/stubbies/pkg/capture"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// ReplayMatchParams matches recorded responses by method and params.
+	ReplayMatchParams = "params"
+	// ReplayMatchSequence serves recorded responses in the order they were captured.
+	ReplayMatchSequence = "sequence"
+
+	// ReplayMissFallback answers unmatched requests with the regular stub logic.
+	ReplayMissFallback = "fallback"
+	// ReplayMissError answers unmatched requests with a JSON-RPC error.
+	ReplayMissError = "error"
+)
+
+type ReplayConfig struct {
+	Enabled bool   `yaml:"enabled" default:"false"`
+	Path    string `yaml:"path"`
+	Match   string `yaml:"match" default:"params"`
+	OnMiss  string `yaml:"onMiss" default:"fallback"`
+}
+
+func (c *ReplayConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.Path == "" {
+		return errors.New("replay.path is required")
+	}
+
+	if c.Match != ReplayMatchParams && c.Match != ReplayMatchSequence {
+		return fmt.Errorf("replay.match must be one of %q or %q", ReplayMatchParams, ReplayMatchSequence)
+	}
+
+	if c.OnMiss != ReplayMissFallback && c.OnMiss != ReplayMissError {
+		return fmt.Errorf("replay.onMiss must be one of %q or %q", ReplayMissFallback, ReplayMissError)
+	}
+
+	return nil
+}
+
+type replayedResponse struct {
+	result json.RawMessage
+	rpcErr *ResponseError
+	err    error
+}
+
+// Replayer answers requests from a capture file recorded by capture.Writer.
+type Replayer struct {
+	log logrus.FieldLogger
+	cfg ReplayConfig
+
+	byKey    map[string][]*replayedResponse
+	sequence []*replayedResponse
+	methods  []string
+	next     int
+
+	mu sync.Mutex
+}
+
+func NewReplayer(log logrus.FieldLogger, conf *ReplayConfig) (*Replayer, error) {
+	entries, err := capture.ReadFile(conf.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Replayer{
+		log:   log,
+		cfg:   *conf,
+		byKey: make(map[string][]*replayedResponse),
+	}
+
+	for _, entry := range entries {
+		replayed, err := newReplayedResponse(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		var request struct {
+			Params []*json.RawMessage `json:"params"`
+		}
+
+		if err := json.Unmarshal(entry.Request, &request); err != nil {
+			return nil, err
+		}
+
+		key, err := replayKey(entry.Method, request.Params)
+		if err != nil {
+			return nil, err
+		}
+
+		r.byKey[key] = append(r.byKey[key], replayed)
+		r.sequence = append(r.sequence, replayed)
+		r.methods = append(r.methods, entry.Method)
+	}
+
+	r.log.WithFields(logrus.Fields{
+		"path":    conf.Path,
+		"entries": len(entries),
+		"match":   conf.Match,
+	}).Info("loaded replay capture")
+
+	return r, nil
+}
+
+func newReplayedResponse(entry *capture.Entry) (*replayedResponse, error) {
+	if entry.Error != "" {
+		return &replayedResponse{err: errors.New(entry.Error)}, nil
+	}
+
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *ResponseError  `json:"error"`
+	}
+
+	if err := json.Unmarshal(entry.Response, &response); err != nil {
+		return nil, err
+	}
+
+	return &replayedResponse{
+		result: response.Result,
+		rpcErr: response.Error,
+	}, nil
+}
+
+func replayKey(method string, params []*json.RawMessage) (string, error) {
+	var buf bytes.Buffer
+
+	buf.WriteString(method)
+
+	for _, param := range params {
+		buf.WriteByte('|')
+
+		if param == nil {
+			buf.WriteString("null")
+
+			continue
+		}
+
+		if err := json.Compact(&buf, *param); err != nil {
+			return "", err
+		}
+	}
+
+	return buf.String(), nil
+}
+
+// Lookup returns the recorded response for the request. ok is false when nothing matched.
+func (r *Replayer) Lookup(id int, method string, params []*json.RawMessage) (resp *Response, ok bool, err error) {
+	replayed, found, err := r.match(method, params)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !found {
+		r.log.WithField("method", method).Debug("no recorded response to replay")
+
+		if r.cfg.OnMiss == ReplayMissFallback {
+			return nil, false, nil
+		}
+
+		return &Response{
+			ID:      id,
+			JSONRPC: "2.0",
+			Error: &ResponseError{
+				Code:    ErrorCodeServerError,
+				Message: fmt.Sprintf("no recorded response for %s", method),
+			},
+		}, true, nil
+	}
+
+	if replayed.err != nil {
+		return nil, true, replayed.err
+	}
+
+	resp = &Response{
+		ID:      id,
+		JSONRPC: "2.0",
+		Error:   replayed.rpcErr,
+	}
+
+	if replayed.result != nil {
+		resp.Result = replayed.result
+	}
+
+	return resp, true, nil
+}
+
+func (r *Replayer) match(method string, params []*json.RawMessage) (*replayedResponse, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cfg.Match == ReplayMatchSequence {
+		// Resync with the next recorded call of the method when the live client skipped recorded calls.
+		for i := r.next; i < len(r.sequence); i++ {
+			if r.methods[i] != method {
+				continue
+			}
+
+			if skipped := i - r.next; skipped > 0 {
+				r.log.WithFields(logrus.Fields{
+					"method":   method,
+					"skipped":  skipped,
+					"expected": r.methods[r.next],
+				}).Warn("replay out of sequence, skipped recorded calls")
+			}
+
+			r.next = i + 1
+
+			return r.sequence[i], true, nil
+		}
+
+		return nil, false, nil
+	}
+
+	key, err := replayKey(method, params)
+	if err != nil {
+		return nil, false, err
+	}
+
+	queue := r.byKey[key]
+	if len(queue) == 0 {
+		return nil, false, nil
+	}
+
+	// Identical requests are answered in recorded order, repeating the last response once exhausted.
+	if len(queue) > 1 {
+		r.byKey[key] = queue[1:]
+	}
+
+	return queue[0], true, nil
+}
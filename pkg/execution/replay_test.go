@@ -0,0 +1,181 @@
+package execution
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethpandaops/stubbies/pkg/capture"
+	"github.com/sirupsen/logrus"
+)
+
+// writeCapture writes entries to a capture file, returning its path.
+func writeCapture(t *testing.T, entries ...*capture.Entry) string {
+	t.Helper()
+
+	var lines []string
+
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lines = append(lines, string(data))
+	}
+
+	path := filepath.Join(t.TempDir(), "capture.jsonl")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// captured returns an entry of a request answered with response, or failed with errMsg.
+func captured(method, params, response, errMsg string) *capture.Entry {
+	entry := capture.NewEntry(time.Now(), "127.0.0.1:1234", method)
+	entry.Request = json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"` + method + `","params":` + params + `}`)
+	entry.Error = errMsg
+
+	if response != "" {
+		entry.Response = json.RawMessage(response)
+	}
+
+	return entry
+}
+
+func TestReplayerLookup(t *testing.T) {
+	path := writeCapture(t,
+		captured("eth_chainId", `[]`, `{"jsonrpc":"2.0","id":1,"result":"0x5"}`, ""),
+		captured("eth_getBlockByNumber", `["0x1", false]`, `{"jsonrpc":"2.0","id":1,"result":{"number":"0x1"}}`, ""),
+		captured("eth_getBlockByNumber", `["0x1",false]`, `{"jsonrpc":"2.0","id":1,"result":null}`, ""),
+		captured("eth_getBlockByNumber", `["0x2",false]`, `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"pruned"}}`, ""),
+		captured("eth_blockNumber", `[]`, "", "connection refused"),
+	)
+
+	type call struct {
+		method   string
+		params   []interface{}
+		expected string
+	}
+
+	const (
+		miss   = "miss"
+		failed = "failed"
+	)
+
+	tests := []struct {
+		name   string
+		match  string
+		onMiss string
+		calls  []call
+	}{
+		{
+			name:  "params",
+			match: ReplayMatchParams,
+			calls: []call{
+				{method: "eth_getBlockByNumber", params: []interface{}{"0x2", false}, expected: `{"code":-32000,"message":"pruned"}`},
+				// Identical requests are answered in order, the last response is repeated.
+				{method: "eth_getBlockByNumber", params: []interface{}{"0x1", false}, expected: `{"number":"0x1"}`},
+				{method: "eth_getBlockByNumber", params: []interface{}{"0x1", false}, expected: `null`},
+				{method: "eth_getBlockByNumber", params: []interface{}{"0x1", false}, expected: `null`},
+				{method: "eth_getBlockByNumber", params: []interface{}{"0x1", true}, expected: miss},
+				{method: "eth_chainId", expected: `"0x5"`},
+				{method: "eth_blockNumber", expected: failed},
+			},
+		},
+		{
+			name:   "params miss error",
+			match:  ReplayMatchParams,
+			onMiss: ReplayMissError,
+			calls: []call{
+				{method: "eth_syncing", expected: `{"code":-32000,"message":"no recorded response for eth_syncing"}`},
+			},
+		},
+		{
+			name:  "sequence",
+			match: ReplayMatchSequence,
+			calls: []call{
+				{method: "eth_chainId", expected: `"0x5"`},
+				{method: "eth_getBlockByNumber", params: []interface{}{"0x9"}, expected: `{"number":"0x1"}`},
+				{method: "eth_getBlockByNumber", expected: `null`},
+				{method: "eth_chainId", expected: miss},
+			},
+		},
+		{
+			name:  "sequence resyncs",
+			match: ReplayMatchSequence,
+			calls: []call{
+				// Skips the recorded chainId and the first two block lookups.
+				{method: "eth_getBlockByNumber", expected: `{"number":"0x1"}`},
+				{method: "eth_blockNumber", expected: failed},
+				{method: "eth_getBlockByNumber", expected: miss},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf := &ReplayConfig{Enabled: true, Path: path, Match: test.match, OnMiss: test.onMiss}
+			if conf.OnMiss == "" {
+				conf.OnMiss = ReplayMissFallback
+			}
+
+			r, err := NewReplayer(logrus.New(), conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, c := range test.calls {
+				resp, ok, err := r.Lookup(1, c.method, rawParams(t, c.params...))
+
+				var got string
+
+				switch {
+				case err != nil:
+					got = failed
+				case !ok:
+					got = miss
+				default:
+					got = encode(t, resp)
+				}
+
+				if got != c.expected {
+					t.Fatalf("call %d of %s answered %s, expected %s", i, c.method, got, c.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestHandlerReplayFallback(t *testing.T) {
+	path := writeCapture(t, captured("eth_chainId", `[]`, `{"jsonrpc":"2.0","id":1,"result":"0x5"}`, ""))
+
+	h := newTestHandler(t, func(conf *Config) {
+		conf.Replay = ReplayConfig{Enabled: true, Path: path, Match: ReplayMatchParams, OnMiss: ReplayMissFallback}
+	})
+
+	tests := []struct {
+		method   string
+		expected string
+	}{
+		{method: "eth_chainId", expected: `"0x5"`},
+		{method: "net_version", expected: `"1"`},
+	}
+
+	for _, test := range tests {
+		resp, err := h.Request(context.Background(), 1, test.method, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := encode(t, resp); got != test.expected {
+			t.Errorf("%s answered %s, expected %s", test.method, got, test.expected)
+		}
+	}
+}
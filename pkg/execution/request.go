@@ -1,7 +1,24 @@
 package execution
 
 type RequestParamsForkchoiceUpdatedV1 struct {
-	HeadBlockHash string `json:"headBlockHash"`
+	HeadBlockHash      string `json:"headBlockHash"`
+	SafeBlockHash      string `json:"safeBlockHash"`
+	FinalizedBlockHash string `json:"finalizedBlockHash"`
+}
+
+type RequestParamsPayloadAttributes struct {
+	Timestamp             string                     `json:"timestamp"`
+	PrevRandao            string                     `json:"prevRandao"`
+	SuggestedFeeRecipient string                     `json:"suggestedFeeRecipient"`
+	Withdrawals           *[]RequestParamsWithdrawal `json:"withdrawals,omitempty"`
+	ParentBeaconBlockRoot *string                    `json:"parentBeaconBlockRoot,omitempty"`
+}
+
+type RequestParamsWithdrawal struct {
+	Index          string `json:"index"`
+	ValidatorIndex string `json:"validatorIndex"`
+	Address        string `json:"address"`
+	Amount         string `json:"amount"`
 }
 
 type RequestParamsNewPayloadV1 struct {
@@ -22,3 +39,5 @@ type RequestParamsNewPayloadV1 struct {
 }
 
 type RequestParamsExchangeCapabilities []string
+
+type RequestParamsGetClientVersionV1 ClientVersionV1
@@ -1,11 +1,48 @@
 package execution
 
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
 type Response struct {
-	ID      int         `json:"id"`
-	JSONRPC string      `json:"jsonrpc"`
-	Result  interface{} `json:"result"`
+	ID      int            `json:"id"`
+	JSONRPC string         `json:"jsonrpc"`
+	Result  interface{}    `json:"result"`
+	Error   *ResponseError `json:"error,omitempty"`
+}
+
+// MarshalJSON omits the result when the response carries an error, as required by JSON-RPC 2.0.
+func (r Response) MarshalJSON() ([]byte, error) {
+	type plain Response
+
+	if r.Error == nil {
+		return json.Marshal(plain(r))
+	}
+
+	return json.Marshal(struct {
+		ID      int            `json:"id"`
+		JSONRPC string         `json:"jsonrpc"`
+		Error   *ResponseError `json:"error"`
+	}{
+		ID:      r.ID,
+		JSONRPC: r.JSONRPC,
+		Error:   r.Error,
+	})
+}
+
+type ResponseError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
 }
 
+const (
+	ErrorCodeServerError    = -32000
+	ErrorCodeMethodNotFound = -32601
+)
+
 type ResultDefault bool
 
 type ResultExchangeTransitionConfigurationV1 struct {
@@ -48,3 +85,67 @@ type ResultGetBlock struct {
 	Timestamp    string   `json:"timestamp"`
 	Transactions []string `json:"transactions"`
 }
+
+type ResultHeader struct {
+	Number        string `json:"number"`
+	Hash          string `json:"hash"`
+	ParentHash    string `json:"parentHash"`
+	Miner         string `json:"miner"`
+	LogsBloom     string `json:"logsBloom"`
+	StateRoot     string `json:"stateRoot"`
+	ReceiptsRoot  string `json:"receiptsRoot"`
+	MixHash       string `json:"mixHash"`
+	Difficulty    string `json:"difficulty"`
+	ExtraData     string `json:"extraData"`
+	GasLimit      string `json:"gasLimit"`
+	GasUsed       string `json:"gasUsed"`
+	Timestamp     string `json:"timestamp"`
+	BaseFeePerGas string `json:"baseFeePerGas"`
+}
+
+type ResultGetBalance string
+
+type ResultNodeInfo struct {
+	ID         string                  `json:"id"`
+	Name       string                  `json:"name"`
+	Enode      string                  `json:"enode"`
+	IP         string                  `json:"ip"`
+	Ports      ResultNodeInfoPorts     `json:"ports"`
+	ListenAddr string                  `json:"listenAddr"`
+	Protocols  ResultNodeInfoProtocols `json:"protocols"`
+}
+
+type ResultNodeInfoPorts struct {
+	Discovery int `json:"discovery"`
+	Listener  int `json:"listener"`
+}
+
+type ResultNodeInfoProtocols struct {
+	Eth ResultNodeInfoEth `json:"eth"`
+}
+
+type ResultNodeInfoEth struct {
+	Network    *big.Int                  `json:"network"`
+	Difficulty string                    `json:"difficulty"`
+	Genesis    string                    `json:"genesis,omitempty"`
+	Config     ResultNodeInfoChainConfig `json:"config"`
+	Head       string                    `json:"head"`
+}
+
+type ResultNodeInfoChainConfig struct {
+	ChainID                 *big.Int `json:"chainId"`
+	TerminalTotalDifficulty string   `json:"terminalTotalDifficulty"`
+}
+
+type ClientVersionV1 struct {
+	Code    string `json:"code"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+}
+
+type ResultGetClientVersionV1 []ClientVersionV1
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
@@ -0,0 +1,347 @@
+package execution
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/creasty/defaults"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// Payload statuses a scenario can return.
+const (
+	StatusValid    = "VALID"
+	StatusInvalid  = "INVALID"
+	StatusSyncing  = "SYNCING"
+	StatusAccepted = "ACCEPTED"
+)
+
+type ScenarioConfig struct {
+	// Path of a scenario file describing the behaviour of newPayload and forkchoiceUpdated over time.
+	Path string `yaml:"path"`
+}
+
+// Scenario is a timeline of execution client behaviour, loaded from a YAML file.
+type Scenario struct {
+	// GenesisTime, SecondsPerSlot and SlotsPerEpoch map payload timestamps to slots and epochs.
+	// GenesisTime is only required by steps matching on slots or epochs.
+	GenesisTime    uint64 `yaml:"genesisTime"`
+	SecondsPerSlot uint64 `yaml:"secondsPerSlot" default:"12"`
+	SlotsPerEpoch  uint64 `yaml:"slotsPerEpoch" default:"32"`
+	// Steps are evaluated in order. For each of status and latency, the first matching step setting it applies.
+	Steps []ScenarioStep `yaml:"steps"`
+}
+
+// ScenarioStep changes the behaviour for the payloads within all of its ranges. Ranges are inclusive, a
+// missing bound is open.
+type ScenarioStep struct {
+	FromSlot  *uint64 `yaml:"fromSlot"`
+	ToSlot    *uint64 `yaml:"toSlot"`
+	FromEpoch *uint64 `yaml:"fromEpoch"`
+	ToEpoch   *uint64 `yaml:"toEpoch"`
+	FromBlock *uint64 `yaml:"fromBlock"`
+	ToBlock   *uint64 `yaml:"toBlock"`
+	// Methods limits the step to methods with these prefixes. Defaults to newPayload and forkchoiceUpdated.
+	Methods []string `yaml:"methods" default:"[\"engine_newPayload\",\"engine_forkchoiceUpdated\"]"`
+	// Status is returned instead of VALID.
+	Status string `yaml:"status"`
+	// InvalidateDescendants returns INVALID for every payload building on a block this step made INVALID.
+	InvalidateDescendants bool `yaml:"invalidateDescendants"`
+	// Latency delays the response.
+	Latency time.Duration `yaml:"latency"`
+}
+
+// UnmarshalYAML applies the defaults to each step, they are not set for slice elements otherwise.
+func (s *ScenarioStep) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := defaults.Set(s); err != nil {
+		return err
+	}
+
+	type plain ScenarioStep
+
+	return unmarshal((*plain)(s))
+}
+
+// LoadScenario reads and validates a scenario file.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Scenario{}
+	if err := defaults.Set(s); err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %w", path, err)
+	}
+
+	return s, nil
+}
+
+func (s *Scenario) Validate() error {
+	if s.SecondsPerSlot == 0 || s.SlotsPerEpoch == 0 {
+		return errors.New("secondsPerSlot and slotsPerEpoch must be greater than 0")
+	}
+
+	for i, step := range s.Steps {
+		switch step.Status {
+		case "", StatusValid, StatusInvalid, StatusSyncing, StatusAccepted:
+		default:
+			return fmt.Errorf("step %d: unknown status %q", i, step.Status)
+		}
+
+		if step.InvalidateDescendants && step.Status != StatusInvalid {
+			return fmt.Errorf("step %d: invalidateDescendants requires status %s", i, StatusInvalid)
+		}
+
+		if step.Latency < 0 {
+			return fmt.Errorf("step %d: latency must not be negative", i)
+		}
+
+		bySlot := step.FromSlot != nil || step.ToSlot != nil || step.FromEpoch != nil || step.ToEpoch != nil
+		if bySlot && s.GenesisTime == 0 {
+			return fmt.Errorf("step %d: genesisTime is required to match on slots or epochs", i)
+		}
+	}
+
+	return nil
+}
+
+// scenarioPosition is where a payload is on the timeline.
+type scenarioPosition struct {
+	slot, epoch, block uint64
+	hasSlot, hasBlock  bool
+}
+
+func inRange(value uint64, from, to *uint64) bool {
+	return (from == nil || value >= *from) && (to == nil || value <= *to)
+}
+
+func (s *ScenarioStep) matches(method string, pos scenarioPosition) bool {
+	matched := false
+
+	for _, prefix := range s.Methods {
+		if strings.HasPrefix(method, prefix) {
+			matched = true
+
+			break
+		}
+	}
+
+	if !matched {
+		return false
+	}
+
+	if s.FromSlot != nil || s.ToSlot != nil || s.FromEpoch != nil || s.ToEpoch != nil {
+		if !pos.hasSlot || !inRange(pos.slot, s.FromSlot, s.ToSlot) || !inRange(pos.epoch, s.FromEpoch, s.ToEpoch) {
+			return false
+		}
+	}
+
+	if s.FromBlock != nil || s.ToBlock != nil {
+		if !pos.hasBlock || !inRange(pos.block, s.FromBlock, s.ToBlock) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// scenarioPayloadStatus is a payload status with the nullable fields of the engine api spec.
+type scenarioPayloadStatus struct {
+	Status          string  `json:"status"`
+	LatestValidHash *string `json:"latestValidHash"`
+	ValidationError *string `json:"validationError"`
+}
+
+type scenarioForkchoiceUpdated struct {
+	PayloadStatus scenarioPayloadStatus `json:"payloadStatus"`
+	PayloadID     *string               `json:"payloadId"`
+}
+
+// scenarioRunner decides the newPayload and forkchoiceUpdated responses of the handler as described by a scenario.
+type scenarioRunner struct {
+	log      logrus.FieldLogger
+	scenario *Scenario
+	storage  *Storage
+
+	mu sync.Mutex
+	// invalid holds the blocks made INVALID whose descendants are invalid too, by hash.
+	invalid map[string]invalidBlock
+}
+
+type invalidBlock struct {
+	number      uint64
+	latestValid string
+}
+
+func newScenarioRunner(log logrus.FieldLogger, scenario *Scenario, storage *Storage) *scenarioRunner {
+	return &scenarioRunner{
+		log:      log,
+		scenario: scenario,
+		storage:  storage,
+		invalid:  make(map[string]invalidBlock),
+	}
+}
+
+func (r *scenarioRunner) position(timestamp, number string) scenarioPosition {
+	var pos scenarioPosition
+
+	if n, ok := parseHexBig(number); ok && n.IsUint64() {
+		pos.block = n.Uint64()
+		pos.hasBlock = true
+	}
+
+	if ts, ok := parseHexBig(timestamp); ok && ts.IsUint64() && r.scenario.GenesisTime != 0 && ts.Uint64() >= r.scenario.GenesisTime {
+		pos.slot = (ts.Uint64() - r.scenario.GenesisTime) / r.scenario.SecondsPerSlot
+		pos.epoch = pos.slot / r.scenario.SlotsPerEpoch
+		pos.hasSlot = true
+	}
+
+	return pos
+}
+
+// behaviour returns the status, and whether it invalidates descendants, and latency of the first matching steps.
+func (r *scenarioRunner) behaviour(method string, pos scenarioPosition) (status string, invalidateDescendants bool, latency time.Duration) {
+	latencySet := false
+
+	for i := range r.scenario.Steps {
+		step := &r.scenario.Steps[i]
+
+		if !step.matches(method, pos) {
+			continue
+		}
+
+		if status == "" && step.Status != "" {
+			status = step.Status
+			invalidateDescendants = step.InvalidateDescendants
+		}
+
+		if !latencySet && step.Latency > 0 {
+			latency = step.Latency
+			latencySet = true
+		}
+	}
+
+	return status, invalidateDescendants, latency
+}
+
+// NewPayload returns the status the scenario gives a payload, nil when it is VALID, and the latency of the response.
+// It is decided before the payload is stored, payloads that end up INVALID or SYNCING are not.
+func (r *scenarioRunner) NewPayload(method string, payload *RequestParamsNewPayloadV1) (*scenarioPayloadStatus, time.Duration) {
+	pos := r.position(payload.Timestamp, payload.BlockNumber)
+	status, invalidateDescendants, latency := r.behaviour(method, pos)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if parent, ok := r.invalid[payload.ParentHash]; ok {
+		r.invalidate(payload.BlockHash, pos.block, parent.latestValid)
+
+		result := invalidStatus(parent.latestValid, "builds on an invalid block")
+
+		return &result, latency
+	}
+
+	var result scenarioPayloadStatus
+
+	switch status {
+	case "", StatusValid:
+		return nil, latency
+	case StatusInvalid:
+		latestValid := payload.ParentHash
+		if invalidateDescendants {
+			r.invalidate(payload.BlockHash, pos.block, latestValid)
+		}
+
+		result = invalidStatus(latestValid, "invalidated by scenario")
+	default:
+		result = scenarioPayloadStatus{Status: status}
+	}
+
+	r.log.WithFields(logrus.Fields{
+		"block_hash": payload.BlockHash,
+		"status":     status,
+	}).Debug("scenario changed payload status")
+
+	return &result, latency
+}
+
+// ForkchoiceUpdated returns the response the scenario gives a forkchoice update, nil when the head is VALID, and
+// the latency of the response. The forkchoice doesn't move to heads that are not VALID.
+func (r *scenarioRunner) ForkchoiceUpdated(method string, state *RequestParamsForkchoiceUpdatedV1) (*scenarioForkchoiceUpdated, time.Duration) {
+	pos := scenarioPosition{}
+	if block := r.storage.GetBlockByHash(state.HeadBlockHash); block != nil {
+		pos = r.position(block.payload.Timestamp, block.payload.BlockNumber)
+	}
+
+	status, _, latency := r.behaviour(method, pos)
+
+	r.mu.Lock()
+	head, invalid := r.invalid[state.HeadBlockHash]
+	r.mu.Unlock()
+
+	switch {
+	case invalid:
+		return &scenarioForkchoiceUpdated{PayloadStatus: invalidStatus(head.latestValid, "head is invalid")}, latency
+	case status == StatusInvalid:
+		return &scenarioForkchoiceUpdated{PayloadStatus: invalidStatus("", "invalidated by scenario")}, latency
+	case status == StatusSyncing || status == StatusAccepted:
+		// ACCEPTED is not a valid forkchoiceUpdated status, a client that has not validated the head is syncing.
+		return &scenarioForkchoiceUpdated{PayloadStatus: scenarioPayloadStatus{Status: StatusSyncing}}, latency
+	}
+
+	return nil, latency
+}
+
+// invalidate records an invalid block whose descendants are invalid too, and forgets the invalid blocks storage
+// would no longer hold.
+func (r *scenarioRunner) invalidate(hash string, number uint64, latestValid string) {
+	r.invalid[hash] = invalidBlock{number: number, latestValid: latestValid}
+
+	for invalidHash, block := range r.invalid {
+		if block.number+retainedBlocks < number {
+			delete(r.invalid, invalidHash)
+		}
+	}
+}
+
+// scenarioDelay waits for the latency of a scenario response.
+func scenarioDelay(ctx context.Context, latency time.Duration) error {
+	if latency <= 0 {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(latency):
+		return nil
+	}
+}
+
+func invalidStatus(latestValidHash, validationError string) scenarioPayloadStatus {
+	status := scenarioPayloadStatus{
+		Status:          StatusInvalid,
+		ValidationError: &validationError,
+	}
+
+	if latestValidHash != "" {
+		status.LatestValidHash = &latestValidHash
+	}
+
+	return status
+}
@@ -0,0 +1,169 @@
+package execution
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeScenario(t *testing.T, scenario string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "scenario.yaml")
+	if err := os.WriteFile(path, []byte(scenario), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadScenario(t *testing.T) {
+	tests := []struct {
+		name     string
+		scenario string
+		err      bool
+	}{
+		{name: "blocks without genesis", scenario: "steps:\n  - fromBlock: 3\n    status: INVALID\n    invalidateDescendants: true\n"},
+		{name: "slots", scenario: "genesisTime: 1000\nsteps:\n  - fromSlot: 3\n    status: SYNCING\n    latency: 1s\n"},
+		{name: "slots without genesis", scenario: "steps:\n  - toEpoch: 3\n    status: SYNCING\n", err: true},
+		{name: "unknown status", scenario: "steps:\n  - status: VALIDATED\n", err: true},
+		{name: "invalidate descendants without invalid", scenario: "steps:\n  - status: SYNCING\n    invalidateDescendants: true\n", err: true},
+		{name: "negative latency", scenario: "steps:\n  - latency: -1s\n", err: true},
+		{name: "zero slots per epoch", scenario: "slotsPerEpoch: 0\n", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := LoadScenario(writeScenario(t, test.scenario))
+			if (err != nil) != test.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestHandlerScenario(t *testing.T) {
+	// Slot 20 is at 1240, epoch 10 starts at slot 40 at 1480.
+	path := writeScenario(t, `
+genesisTime: 1000
+secondsPerSlot: 12
+slotsPerEpoch: 4
+steps:
+  - fromBlock: 3
+    toBlock: 3
+    status: INVALID
+    invalidateDescendants: true
+  - fromSlot: 20
+    toSlot: 20
+    status: SYNCING
+  - methods: [engine_forkchoiceUpdated]
+    fromBlock: 7
+    status: INVALID
+  - fromEpoch: 10
+    status: ACCEPTED
+`)
+
+	h := newTestHandler(t, func(conf *Config) {
+		conf.Scenario.Path = path
+	})
+
+	type status struct {
+		Status          string  `json:"status"`
+		LatestValidHash *string `json:"latestValidHash"`
+	}
+
+	tests := []struct {
+		name        string
+		payload     *RequestParamsNewPayloadV1
+		head        string
+		status      string
+		latestValid string
+		// stored is whether the payload was stored, moved the forkchoice head after the update.
+		stored bool
+		moved  string
+	}{
+		{name: "valid", payload: testPayload("0x1", "0x3f4", "0x01", "0x00"), status: StatusValid, latestValid: "0x01", stored: true},
+		{name: "valid parent", payload: testPayload("0x2", "0x400", "0x02", "0x01"), status: StatusValid, latestValid: "0x02", stored: true},
+		{name: "invalid block", payload: testPayload("0x3", "0x40c", "0x03", "0x02"), status: StatusInvalid, latestValid: "0x02"},
+		{name: "invalid descendant", payload: testPayload("0x4", "0x418", "0x04", "0x03"), status: StatusInvalid, latestValid: "0x02"},
+		{name: "invalid descendant of descendant", payload: testPayload("0x5", "0x424", "0x05", "0x04"), status: StatusInvalid, latestValid: "0x02"},
+		{name: "invalid head", head: "0x05", status: StatusInvalid, latestValid: "0x02", moved: ""},
+		{name: "syncing slot", payload: testPayload("0x5", "0x4d8", "0x15", "0x02"), status: StatusSyncing},
+		{name: "accepted epoch", payload: testPayload("0x6", "0x5c8", "0x16", "0x02"), status: StatusAccepted, stored: true},
+		{name: "accepted head is syncing", head: "0x16", status: StatusSyncing, moved: ""},
+		{name: "valid head", head: "0x02", status: StatusValid, latestValid: "0x02", moved: "0x02"},
+		{name: "invalid head by scenario", payload: testPayload("0x7", "0x5d4", "0x17", "0x16"), status: StatusAccepted, stored: true},
+		{name: "head without latest valid hash", head: "0x17", status: StatusInvalid, moved: "0x02"},
+	}
+
+	for _, test := range tests {
+		method, params := "engine_forkchoiceUpdatedV1", rawParams(t, RequestParamsForkchoiceUpdatedV1{HeadBlockHash: test.head}, nil)
+		if test.payload != nil {
+			method, params = "engine_newPayloadV1", rawParams(t, test.payload)
+		}
+
+		resp, err := h.Request(context.Background(), 1, method, params)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		var got status
+
+		if test.payload != nil {
+			err = json.Unmarshal([]byte(encode(t, resp)), &got)
+		} else {
+			var result struct {
+				PayloadStatus status `json:"payloadStatus"`
+			}
+
+			err = json.Unmarshal([]byte(encode(t, resp)), &result)
+			got = result.PayloadStatus
+		}
+
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		latestValid := ""
+		if got.LatestValidHash != nil {
+			latestValid = *got.LatestValidHash
+		}
+
+		if got.Status != test.status || latestValid != test.latestValid {
+			t.Errorf("%s: answered %s with latest valid hash %q, expected %s with %q", test.name, got.Status, latestValid, test.status, test.latestValid)
+		}
+
+		if test.payload != nil {
+			if stored := h.storage.GetBlockByHash(test.payload.BlockHash) != nil; stored != test.stored {
+				t.Errorf("%s: payload stored is %t, expected %t", test.name, stored, test.stored)
+			}
+		} else if head := h.storage.GetForkchoice().HeadBlockHash; head != test.moved {
+			t.Errorf("%s: forkchoice head is %s, expected %s", test.name, head, test.moved)
+		}
+	}
+}
+
+func TestHandlerScenarioLatency(t *testing.T) {
+	h := newTestHandler(t, func(conf *Config) {
+		conf.Scenario.Path = writeScenario(t, "steps:\n  - methods: [engine_newPayload]\n    latency: 1h\n")
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := h.Request(ctx, 1, "engine_newPayloadV1", rawParams(t, testPayload("0x1", "0x3f4", "0x01", "0x00"))); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("delayed payload returned %v, expected %v", err, context.DeadlineExceeded)
+	}
+
+	// Forkchoice updates are not delayed.
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if _, err := h.Request(ctx, 1, "engine_forkchoiceUpdatedV1", rawParams(t, RequestParamsForkchoiceUpdatedV1{HeadBlockHash: "0x01"}, nil)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,302 @@
+package execution
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	starjson "go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+type ScriptConfig struct {
+	// Path of a Starlark script defining handle(method, params, storage), called before every request.
+	Path string `yaml:"path"`
+	// MaxSteps aborts a single call of the script after this many execution steps.
+	MaxSteps uint64 `yaml:"maxSteps" default:"1000000"`
+}
+
+func (c *ScriptConfig) Validate() error {
+	if c.Path != "" && c.MaxSteps == 0 {
+		return errors.New("script.maxSteps must be greater than 0")
+	}
+
+	return nil
+}
+
+// scriptOutcome is what a script decided for a request.
+type scriptOutcome struct {
+	result json.RawMessage
+	rpcErr *ResponseError
+	delay  time.Duration
+	// handled is set when the script answered the request, rather than only delaying it.
+	handled bool
+}
+
+// Script lets a Starlark script answer, fail or delay requests. The script defines
+//
+//	def handle(method, params, storage):
+//
+// returning None to leave the request to the regular stub logic, or a dict with any of
+// "result" (any JSON value), "error" (a message, or a dict with "code", "message" and "data") and
+// "delay" (seconds). storage offers read-only access to the stored blocks and forkchoice. handle is called
+// concurrently, so the globals are frozen after loading and can't hold mutable state.
+type Script struct {
+	log logrus.FieldLogger
+	cfg ScriptConfig
+
+	handle  starlark.Callable
+	storage *starlarkstruct.Module
+}
+
+// NewScript loads and runs the script at conf.Path, which has to define handle.
+func NewScript(log logrus.FieldLogger, conf *ScriptConfig, storage *Storage) (*Script, error) {
+	s := &Script{
+		log:     log,
+		cfg:     *conf,
+		storage: storageModule(storage),
+	}
+
+	thread := s.newThread("load")
+
+	globals, err := starlark.ExecFile(thread, conf.Path, nil, starlark.StringDict{
+		"json": starjson.Module,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load script: %w", err)
+	}
+
+	// handle is called concurrently, scripts can't keep mutable state between calls.
+	globals.Freeze()
+
+	handle, ok := globals["handle"].(starlark.Callable)
+	if !ok {
+		return nil, fmt.Errorf("script %s must define a handle(method, params, storage) function", conf.Path)
+	}
+
+	s.handle = handle
+
+	return s, nil
+}
+
+func (s *Script) newThread(name string) *starlark.Thread {
+	thread := &starlark.Thread{
+		Name: name,
+		Print: func(_ *starlark.Thread, msg string) {
+			s.log.WithField("thread", name).Info(msg)
+		},
+	}
+
+	thread.SetMaxExecutionSteps(s.cfg.MaxSteps)
+
+	return thread
+}
+
+// call runs handle for a request.
+func (s *Script) call(ctx context.Context, method string, params []*json.RawMessage) (scriptOutcome, error) {
+	thread := s.newThread(method)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			thread.Cancel("request cancelled")
+		case <-done:
+		}
+	}()
+
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return scriptOutcome{}, err
+	}
+
+	decoded, err := decodeJSON(thread, raw)
+	if err != nil {
+		return scriptOutcome{}, err
+	}
+
+	value, err := starlark.Call(thread, s.handle, starlark.Tuple{starlark.String(method), decoded, s.storage}, nil)
+	if err != nil {
+		return scriptOutcome{}, fmt.Errorf("script failed: %w", err)
+	}
+
+	return s.outcome(thread, value)
+}
+
+func (s *Script) outcome(thread *starlark.Thread, value starlark.Value) (scriptOutcome, error) {
+	var outcome scriptOutcome
+
+	if value == starlark.None {
+		return outcome, nil
+	}
+
+	dict, ok := value.(*starlark.Dict)
+	if !ok {
+		return outcome, fmt.Errorf("script returned %s, expected None or a dict", value.Type())
+	}
+
+	for _, item := range dict.Items() {
+		key, ok := starlark.AsString(item[0])
+		if !ok {
+			return outcome, fmt.Errorf("script returned a dict with key %s, expected a string", item[0])
+		}
+
+		switch key {
+		case "result":
+			encoded, err := encodeJSON(thread, item[1])
+			if err != nil {
+				return outcome, err
+			}
+
+			outcome.result = encoded
+			outcome.handled = true
+		case "error":
+			rpcErr, err := scriptError(thread, item[1])
+			if err != nil {
+				return outcome, err
+			}
+
+			outcome.rpcErr = rpcErr
+			outcome.handled = true
+		case "delay":
+			seconds, ok := starlark.AsFloat(item[1])
+			if !ok || seconds < 0 {
+				return outcome, fmt.Errorf("script returned delay %s, expected a positive number of seconds", item[1])
+			}
+
+			outcome.delay = time.Duration(seconds * float64(time.Second))
+		default:
+			return outcome, fmt.Errorf("script returned unknown key %q", key)
+		}
+	}
+
+	if outcome.result != nil && outcome.rpcErr != nil {
+		return outcome, errors.New("script returned both a result and an error")
+	}
+
+	return outcome, nil
+}
+
+func scriptError(thread *starlark.Thread, value starlark.Value) (*ResponseError, error) {
+	if message, ok := starlark.AsString(value); ok {
+		return &ResponseError{Code: ErrorCodeServerError, Message: message}, nil
+	}
+
+	encoded, err := encodeJSON(thread, value)
+	if err != nil {
+		return nil, err
+	}
+
+	rpcErr := &ResponseError{Code: ErrorCodeServerError}
+	if err := json.Unmarshal(encoded, rpcErr); err != nil {
+		return nil, fmt.Errorf("script returned an invalid error: %w", err)
+	}
+
+	return rpcErr, nil
+}
+
+func encodeJSON(thread *starlark.Thread, value starlark.Value) (json.RawMessage, error) {
+	encoded, err := starlark.Call(thread, starjson.Module.Members["encode"], starlark.Tuple{value}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	s, _ := starlark.AsString(encoded)
+
+	return json.RawMessage(s), nil
+}
+
+func decodeJSON(thread *starlark.Thread, data []byte) (starlark.Value, error) {
+	return starlark.Call(thread, starjson.Module.Members["decode"], starlark.Tuple{starlark.String(data)}, nil)
+}
+
+// storageModule exposes a read-only view of storage to scripts:
+//
+//	storage.block(hash)  the newPayload params of a stored block, or None
+//	storage.latest()     the newPayload params of the highest stored block, or None
+//	storage.forkchoice() the latest forkchoice state
+//	storage.count()      the number of stored blocks
+func storageModule(storage *Storage) *starlarkstruct.Module {
+	blockValue := func(thread *starlark.Thread, block *Block) (starlark.Value, error) {
+		if block == nil || block.raw == nil {
+			return starlark.None, nil
+		}
+
+		return decodeJSON(thread, *block.raw)
+	}
+
+	return &starlarkstruct.Module{
+		Name: "storage",
+		Members: starlark.StringDict{
+			"block": starlark.NewBuiltin("block", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+				var hash string
+				if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 1, &hash); err != nil {
+					return nil, err
+				}
+
+				return blockValue(thread, storage.GetBlockByHash(hash))
+			}),
+			"latest": starlark.NewBuiltin("latest", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+				if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 0); err != nil {
+					return nil, err
+				}
+
+				return blockValue(thread, storage.GetLatestBlock())
+			}),
+			"forkchoice": starlark.NewBuiltin("forkchoice", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+				if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 0); err != nil {
+					return nil, err
+				}
+
+				data, err := json.Marshal(storage.GetForkchoice())
+				if err != nil {
+					return nil, err
+				}
+
+				return decodeJSON(thread, data)
+			}),
+			"count": starlark.NewBuiltin("count", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+				if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 0); err != nil {
+					return nil, err
+				}
+
+				return starlark.MakeInt(storage.Count()), nil
+			}),
+		},
+	}
+}
+
+// runScript lets the script answer or delay a request. ok is set when the script answered it.
+func (h *Handler) runScript(ctx context.Context, id int, method string, params []*json.RawMessage) (resp *Response, ok bool, err error) {
+	outcome, err := h.script.call(ctx, method, params)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if outcome.delay > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		case <-time.After(outcome.delay):
+		}
+	}
+
+	if !outcome.handled {
+		return nil, false, nil
+	}
+
+	resp = &Response{
+		ID:      id,
+		JSONRPC: "2.0",
+		Result:  outcome.result,
+		Error:   outcome.rpcErr,
+	}
+
+	return resp, true, nil
+}
@@ -0,0 +1,200 @@
+package execution
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.starlark.net/starlark"
+)
+
+func TestScriptOutcome(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		result  string
+		rpcErr  *ResponseError
+		delay   time.Duration
+		handled bool
+		err     bool
+	}{
+		{name: "none", value: `None`},
+		{name: "empty", value: `{}`},
+		{name: "result", value: `{"result": {"a": [1, "0x2", None, True]}}`, result: `{"a":[1,"0x2",null,true]}`, handled: true},
+		{name: "none result", value: `{"result": None}`, result: `null`, handled: true},
+		{name: "error message", value: `{"error": "failed"}`, rpcErr: &ResponseError{Code: ErrorCodeServerError, Message: "failed"}, handled: true},
+		{
+			name:    "error dict",
+			value:   `{"error": {"code": -38001, "message": "unknown payload", "data": {"id": "0x1"}}}`,
+			rpcErr:  &ResponseError{Code: -38001, Message: "unknown payload", Data: json.RawMessage(`{"id":"0x1"}`)},
+			handled: true,
+		},
+		{name: "error dict without code", value: `{"error": {"message": "failed"}}`, rpcErr: &ResponseError{Code: ErrorCodeServerError, Message: "failed"}, handled: true},
+		{name: "delay", value: `{"delay": 1.5}`, delay: 1500 * time.Millisecond},
+		{name: "delayed result", value: `{"delay": 2, "result": False}`, result: `false`, delay: 2 * time.Second, handled: true},
+		{name: "negative delay", value: `{"delay": -1}`, err: true},
+		{name: "delay not a number", value: `{"delay": "1s"}`, err: true},
+		{name: "result and error", value: `{"result": 1, "error": "failed"}`, err: true},
+		{name: "invalid error", value: `{"error": {"code": "x"}}`, err: true},
+		{name: "unknown key", value: `{"status": "VALID"}`, err: true},
+		{name: "key not a string", value: `{1: "VALID"}`, err: true},
+		{name: "not a dict", value: `"VALID"`, err: true},
+	}
+
+	s := &Script{log: logrus.New(), cfg: ScriptConfig{MaxSteps: 1000}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			thread := s.newThread(test.name)
+
+			value, err := starlark.Eval(thread, "outcome", test.value, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			outcome, err := s.outcome(thread, value)
+			if (err != nil) != test.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if test.err {
+				return
+			}
+
+			if string(outcome.result) != test.result {
+				t.Errorf("result is %s, expected %s", outcome.result, test.result)
+			}
+
+			if got, expected := encodeError(t, outcome.rpcErr), encodeError(t, test.rpcErr); got != expected {
+				t.Errorf("error is %s, expected %s", got, expected)
+			}
+
+			if outcome.delay != test.delay {
+				t.Errorf("delay is %s, expected %s", outcome.delay, test.delay)
+			}
+
+			if outcome.handled != test.handled {
+				t.Errorf("handled is %t, expected %t", outcome.handled, test.handled)
+			}
+		})
+	}
+}
+
+func encodeError(t *testing.T, rpcErr *ResponseError) string {
+	t.Helper()
+
+	data, err := json.Marshal(rpcErr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+func TestHandlerScript(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.star")
+
+	script := `
+def handle(method, params, storage):
+    if method == "eth_chainId":
+        return {"result": "0x5"}
+    if method == "eth_blockNumber":
+        return {"result": "0x%x" % storage.count()}
+    if method == "eth_getBlockByNumber" and params[0] == "0x9":
+        return {"error": {"code": -32001, "message": "pruned"}}
+    if method == "eth_syncing":
+        return {"delay": 60}
+    if method == "eth_call":
+        fail("broken")
+    return None
+`
+
+	if err := os.WriteFile(path, []byte(script), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	h := newTestHandler(t, func(conf *Config) {
+		conf.Script.Path = path
+	})
+
+	if _, err := h.Request(context.Background(), 1, "engine_newPayloadV1", rawParams(t, testPayload("0x1", "0x3f4", "0x01", "0x00"))); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method   string
+		params   []interface{}
+		expected string
+		err      error
+	}{
+		{method: "eth_chainId", expected: `"0x5"`},
+		{method: "eth_blockNumber", expected: `"0x1"`},
+		{method: "eth_getBlockByNumber", params: []interface{}{"0x9", false}, expected: `{"code":-32001,"message":"pruned"}`},
+		// Requests the script leaves alone are answered by the stub.
+		{method: "eth_getBlockByNumber", params: []interface{}{"0x2", false}, expected: `null`},
+		{method: "net_version", expected: `"1"`},
+		{method: "eth_syncing", err: context.DeadlineExceeded},
+		{method: "eth_call", err: errors.New("script failed")},
+	}
+
+	for _, test := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+
+		resp, err := h.Request(ctx, 1, test.method, rawParams(t, test.params...))
+
+		cancel()
+
+		if test.err != nil {
+			if err == nil {
+				t.Errorf("%s answered %s, expected an error", test.method, encode(t, resp))
+			} else if errors.Is(test.err, context.DeadlineExceeded) && !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("%s failed with %v, expected %v", test.method, err, test.err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s failed: %v", test.method, err)
+
+			continue
+		}
+
+		if got := encode(t, resp); got != test.expected {
+			t.Errorf("%s answered %s, expected %s", test.method, got, test.expected)
+		}
+	}
+}
+
+func TestNewScript(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		err    bool
+	}{
+		{name: "handle", script: "def handle(method, params, storage):\n    return None\n"},
+		{name: "no handle", script: "x = 1\n", err: true},
+		{name: "handle not callable", script: "handle = 1\n", err: true},
+		{name: "syntax error", script: "def handle(:\n", err: true},
+		{name: "endless load", script: "def loop():\n    for i in range(100000000):\n        pass\nloop()\n", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "script.star")
+			if err := os.WriteFile(path, []byte(test.script), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := NewScript(logrus.New(), &ScriptConfig{Path: path, MaxSteps: 10000}, newStorage(logrus.New()))
+			if (err != nil) != test.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
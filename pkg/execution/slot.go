@@ -0,0 +1,314 @@
+package execution
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// slotRetention is the number of slots a timeline is kept open for late calls before it is written out.
+const slotRetention = 2
+
+type SlotTimingConfig struct {
+	Enabled bool `yaml:"enabled" default:"false"`
+	// GenesisTime is the beacon chain genesis time in unix seconds.
+	GenesisTime    uint64 `yaml:"genesisTime"`
+	SecondsPerSlot uint64 `yaml:"secondsPerSlot" default:"12"`
+	// LogPath optionally appends a JSON line with the timeline of every slot.
+	LogPath string `yaml:"logPath"`
+}
+
+func (c *SlotTimingConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.GenesisTime == 0 {
+		return errors.New("slotTiming.genesisTime is required")
+	}
+
+	if c.SecondsPerSlot == 0 {
+		return errors.New("slotTiming.secondsPerSlot must be greater than 0")
+	}
+
+	return nil
+}
+
+// SlotCall is a single engine api call within a slot.
+type SlotCall struct {
+	Method string `json:"method"`
+	// OffsetMS is the time since the start of the slot the call arrived at. Calls preparing a
+	// proposal can arrive before their slot starts and have a negative offset.
+	OffsetMS  int64  `json:"offsetMs"`
+	BlockHash string `json:"blockHash,omitempty"`
+}
+
+// SlotTimeline is the sequence of engine api calls related to a single slot.
+type SlotTimeline struct {
+	Slot      uint64     `json:"slot"`
+	SlotStart time.Time  `json:"slotStart"`
+	Calls     []SlotCall `json:"calls"`
+	// BlockHash is the hash of the payload received for the slot, if any.
+	BlockHash string `json:"blockHash,omitempty"`
+	// NewPayloadDelayMS is the time between the payload timestamp and its arrival.
+	NewPayloadDelayMS *int64 `json:"newPayloadDelayMs,omitempty"`
+	// BuildMS is the time between the forkchoice update with payload attributes and the getPayload call.
+	BuildMS *int64 `json:"buildMs,omitempty"`
+	// ImportMS is the time between the newPayload call and the forkchoice update making the payload head.
+	ImportMS *int64 `json:"importMs,omitempty"`
+
+	attributesAt time.Time
+	newPayloadAt time.Time
+	imported     bool
+}
+
+// slotTimer relates engine api calls to beacon chain slots.
+type slotTimer struct {
+	log     logrus.FieldLogger
+	cfg     SlotTimingConfig
+	metrics Metrics
+	storage *Storage
+
+	mu        sync.Mutex
+	timelines map[uint64]*SlotTimeline
+	// proposalSlot is the slot of the latest payload attributes, which getPayload calls refer to.
+	proposalSlot uint64
+	hasProposal  bool
+
+	file    *os.File
+	encoder *json.Encoder
+}
+
+func newSlotTimer(log logrus.FieldLogger, conf *SlotTimingConfig, metrics Metrics, storage *Storage) (*slotTimer, error) {
+	t := &slotTimer{
+		log:       log,
+		cfg:       *conf,
+		metrics:   metrics,
+		storage:   storage,
+		timelines: make(map[uint64]*SlotTimeline),
+	}
+
+	if conf.LogPath != "" {
+		file, err := os.OpenFile(conf.LogPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open slot timing log: %w", err)
+		}
+
+		t.file = file
+		t.encoder = json.NewEncoder(file)
+	}
+
+	return t, nil
+}
+
+func (t *slotTimer) slotStart(slot uint64) time.Time {
+	return time.Unix(int64(t.cfg.GenesisTime+slot*t.cfg.SecondsPerSlot), 0)
+}
+
+func (t *slotTimer) slotAt(now time.Time) uint64 {
+	seconds := now.Unix()
+	if seconds < int64(t.cfg.GenesisTime) {
+		return 0
+	}
+
+	return (uint64(seconds) - t.cfg.GenesisTime) / t.cfg.SecondsPerSlot
+}
+
+func (t *slotTimer) slotOfTimestamp(timestamp string) (uint64, bool) {
+	ts, ok := parseHexBig(timestamp)
+	if !ok || !ts.IsUint64() || ts.Uint64() < t.cfg.GenesisTime {
+		return 0, false
+	}
+
+	return (ts.Uint64() - t.cfg.GenesisTime) / t.cfg.SecondsPerSlot, true
+}
+
+func (t *slotTimer) timeline(slot uint64) *SlotTimeline {
+	timeline, ok := t.timelines[slot]
+	if !ok {
+		timeline = &SlotTimeline{
+			Slot:      slot,
+			SlotStart: t.slotStart(slot),
+			Calls:     []SlotCall{},
+		}
+
+		t.timelines[slot] = timeline
+	}
+
+	return timeline
+}
+
+func (t *slotTimer) record(timeline *SlotTimeline, now time.Time, method, blockHash string) {
+	offset := now.Sub(timeline.SlotStart)
+
+	timeline.Calls = append(timeline.Calls, SlotCall{
+		Method:    method,
+		OffsetMS:  offset.Milliseconds(),
+		BlockHash: blockHash,
+	})
+
+	t.metrics.ObserveSlotCallOffset(method, offset)
+}
+
+// Observe relates an engine api call that arrived at now to its slot.
+func (t *slotTimer) Observe(now time.Time, method string, params []*json.RawMessage) {
+	if !strings.HasPrefix(method, "engine_") {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(method, "engine_newPayload"):
+		t.observeNewPayload(now, method, params)
+	case strings.HasPrefix(method, "engine_forkchoiceUpdated"):
+		t.observeForkchoiceUpdated(now, method, params)
+	case strings.HasPrefix(method, "engine_getPayload"):
+		t.observeGetPayload(now, method)
+	}
+
+	t.flush(t.slotAt(now), false)
+}
+
+func (t *slotTimer) observeNewPayload(now time.Time, method string, params []*json.RawMessage) {
+	if len(params) < 1 || params[0] == nil {
+		return
+	}
+
+	var payload RequestParamsNewPayloadV1
+	if err := json.Unmarshal(*params[0], &payload); err != nil {
+		return
+	}
+
+	slot, ok := t.slotOfTimestamp(payload.Timestamp)
+	if !ok {
+		return
+	}
+
+	timeline := t.timeline(slot)
+	t.record(timeline, now, method, payload.BlockHash)
+
+	delay := now.Sub(timeline.SlotStart)
+	delayMS := delay.Milliseconds()
+
+	timeline.BlockHash = payload.BlockHash
+	timeline.NewPayloadDelayMS = &delayMS
+	timeline.newPayloadAt = now
+
+	t.metrics.ObserveNewPayloadDelay(method, delay)
+}
+
+func (t *slotTimer) observeForkchoiceUpdated(now time.Time, method string, params []*json.RawMessage) {
+	if len(params) < 1 || params[0] == nil {
+		return
+	}
+
+	var state RequestParamsForkchoiceUpdatedV1
+	if err := json.Unmarshal(*params[0], &state); err != nil {
+		return
+	}
+
+	if len(params) > 1 && params[1] != nil {
+		var attributes *RequestParamsPayloadAttributes
+		if err := json.Unmarshal(*params[1], &attributes); err == nil && attributes != nil {
+			if slot, ok := t.slotOfTimestamp(attributes.Timestamp); ok {
+				timeline := t.timeline(slot)
+				t.record(timeline, now, method, state.HeadBlockHash)
+
+				timeline.attributesAt = now
+				t.proposalSlot = slot
+				t.hasProposal = true
+
+				return
+			}
+		}
+	}
+
+	// Forkchoice updates without attributes belong to the slot of their head block.
+	slot := t.slotAt(now)
+
+	if block := t.storage.GetBlockByHash(state.HeadBlockHash); block != nil {
+		if s, ok := t.slotOfTimestamp(block.payload.Timestamp); ok {
+			slot = s
+		}
+	}
+
+	timeline := t.timeline(slot)
+	t.record(timeline, now, method, state.HeadBlockHash)
+
+	if timeline.BlockHash == state.HeadBlockHash && !timeline.newPayloadAt.IsZero() && !timeline.imported {
+		imported := now.Sub(timeline.newPayloadAt)
+		importMS := imported.Milliseconds()
+
+		timeline.ImportMS = &importMS
+		timeline.imported = true
+
+		t.metrics.ObserveBlockImport(imported)
+	}
+}
+
+func (t *slotTimer) observeGetPayload(now time.Time, method string) {
+	if !t.hasProposal {
+		return
+	}
+
+	timeline := t.timeline(t.proposalSlot)
+	t.record(timeline, now, method, "")
+
+	if !timeline.attributesAt.IsZero() && timeline.BuildMS == nil {
+		build := now.Sub(timeline.attributesAt)
+		buildMS := build.Milliseconds()
+
+		timeline.BuildMS = &buildMS
+
+		t.metrics.ObserveBlockBuild(build)
+	}
+}
+
+// flush writes out and forgets timelines that are older than the retention, or all of them when all is set.
+func (t *slotTimer) flush(current uint64, all bool) {
+	slots := make([]uint64, 0, len(t.timelines))
+
+	for slot := range t.timelines {
+		if all || slot+slotRetention < current {
+			slots = append(slots, slot)
+		}
+	}
+
+	sort.Slice(slots, func(i, j int) bool { return slots[i] < slots[j] })
+
+	for _, slot := range slots {
+		timeline := t.timelines[slot]
+		delete(t.timelines, slot)
+
+		if t.encoder == nil {
+			continue
+		}
+
+		if err := t.encoder.Encode(timeline); err != nil {
+			t.log.WithError(err).Error("Failed to write slot timeline")
+		}
+	}
+}
+
+// Close writes out all open timelines and closes the log.
+func (t *slotTimer) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.flush(0, true)
+
+	if t.file == nil {
+		return nil
+	}
+
+	return t.file.Close()
+}
@@ -11,12 +11,18 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// retainedBlocks is how far below the latest block, 6 epochs, blocks are kept.
+const retainedBlocks = 192
+
 type Storage struct {
 	log logrus.FieldLogger
 
 	hashMap     map[string]*Block
 	numberMap   map[*big.Int]*Block
 	latestBlock *Block
+	forkchoice  RequestParamsForkchoiceUpdatedV1
+
+	scheduler *gocron.Scheduler
 
 	mu sync.Mutex
 }
@@ -30,9 +36,13 @@ func newStorage(log logrus.FieldLogger) *Storage {
 	}
 }
 
-func (s *Storage) Start(ctx context.Context) {
-	if err := s.startCrons(ctx); err != nil {
-		s.log.WithError(err).Fatal("Failed to start crons")
+func (s *Storage) Start(ctx context.Context) error {
+	return s.startCrons(ctx)
+}
+
+func (s *Storage) Stop() {
+	if s.scheduler != nil {
+		s.scheduler.Stop()
 	}
 }
 
@@ -47,6 +57,8 @@ func (s *Storage) startCrons(ctx context.Context) error {
 
 	c.StartAsync()
 
+	s.scheduler = c
+
 	return nil
 }
 
@@ -58,12 +70,12 @@ func (s *Storage) cleanUp() {
 		return
 	}
 
-	// delete blocks that are older than 6 epochs (192 blocks) from latest
+	// delete blocks that are older than retainedBlocks from latest
 	for number, block := range s.numberMap {
 		diff := new(big.Int)
 		diff = diff.Sub(s.latestBlock.Number, number)
 
-		if diff.Cmp(big.NewInt(192)) > 0 {
+		if diff.Cmp(big.NewInt(retainedBlocks)) > 0 {
 			delete(s.numberMap, block.Number)
 			delete(s.hashMap, block.payload.BlockHash)
 		}
@@ -84,6 +96,14 @@ func (s *Storage) GetBlockByNumber(number *big.Int) *Block {
 	return s.numberMap[number]
 }
 
+// Count returns the number of blocks held in storage.
+func (s *Storage) Count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.hashMap)
+}
+
 func (s *Storage) GetLatestBlock() *Block {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -111,3 +131,22 @@ func (s *Storage) AddBlock(payload *RequestParamsNewPayloadV1, raw *json.RawMess
 		s.latestBlock = block
 	}
 }
+
+// SetForkchoice records the latest forkchoice state and returns the previous one.
+func (s *Storage) SetForkchoice(state RequestParamsForkchoiceUpdatedV1) RequestParamsForkchoiceUpdatedV1 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.forkchoice
+	s.forkchoice = state
+
+	return previous
+}
+
+// GetForkchoice returns the latest forkchoice state sent by the consensus client.
+func (s *Storage) GetForkchoice() RequestParamsForkchoiceUpdatedV1 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.forkchoice
+}
@@ -0,0 +1,14 @@
+// Package health holds the response of the stubbies health endpoints, shared by the server and its clients.
+package health
+
+// Statuses of a Response and its checks.
+const (
+	StatusOK        = "ok"
+	StatusUnhealthy = "unhealthy"
+)
+
+// Response is the body of the /healthz, /readyz and /livez endpoints.
+type Response struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
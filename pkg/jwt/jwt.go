@@ -0,0 +1,98 @@
+package jwt
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v4"
+)
+
+// MaxIssuedAtDrift is the maximum allowed difference between the iat claim and the local clock, as per the Engine API spec.
+const MaxIssuedAtDrift = 60 * time.Second
+
+var (
+	ErrMissingToken = errors.New("missing bearer token")
+	ErrStaleToken   = errors.New("token issued at is outside the allowed drift")
+)
+
+// Claims are the claims used by the Engine API authentication scheme.
+type Claims struct {
+	ID              string `json:"id,omitempty"`
+	ClientVersion   string `json:"clv,omitempty"`
+	IssuedAtSeconds int64  `json:"iat"`
+}
+
+func (c *Claims) Valid() error {
+	drift := time.Since(time.Unix(c.IssuedAtSeconds, 0))
+	if drift > MaxIssuedAtDrift || drift < -MaxIssuedAtDrift {
+		return ErrStaleToken
+	}
+
+	return nil
+}
+
+// ParseSecret decodes a 32 byte hex encoded secret, with or without a 0x prefix.
+func ParseSecret(secret string) ([]byte, error) {
+	decoded, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(secret), "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwt secret: %w", err)
+	}
+
+	if len(decoded) != 32 {
+		return nil, fmt.Errorf("invalid jwt secret: expected 32 bytes, got %d", len(decoded))
+	}
+
+	return decoded, nil
+}
+
+// LoadSecret reads a hex encoded secret from a file, as written by execution clients.
+func LoadSecret(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSecret(string(data))
+}
+
+// NewToken returns a freshly signed HS256 token for the secret. id is optional.
+func NewToken(secret []byte, id string) (string, error) {
+	return gojwt.NewWithClaims(gojwt.SigningMethodHS256, &Claims{
+		ID:              id,
+		IssuedAtSeconds: time.Now().Unix(),
+	}).SignedString(secret)
+}
+
+// Verify checks the token is signed with the secret using HS256, as required by the engine api, and was issued
+// recently.
+func Verify(token string, secret []byte) (*Claims, error) {
+	claims := &Claims{}
+
+	_, err := gojwt.ParseWithClaims(token, claims, func(t *gojwt.Token) (interface{}, error) {
+		if t.Method != gojwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+
+		return secret, nil
+	}, gojwt.WithValidMethods([]string{gojwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// FromAuthorizationHeader extracts the token from an "Authorization: Bearer <token>" header value.
+func FromAuthorizationHeader(header string) (string, error) {
+	const prefix = "Bearer "
+
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", ErrMissingToken
+	}
+
+	return header[len(prefix):], nil
+}
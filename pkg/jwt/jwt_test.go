@@ -0,0 +1,109 @@
+package jwt
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v4"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func sign(t *testing.T, method gojwt.SigningMethod, issuedAt time.Time, secret interface{}) string {
+	t.Helper()
+
+	token, err := gojwt.NewWithClaims(method, &Claims{ID: "lighthouse", IssuedAtSeconds: issuedAt.Unix()}).SignedString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+		valid bool
+	}{
+		{name: "hs256", token: sign(t, gojwt.SigningMethodHS256, now, testSecret), valid: true},
+		{name: "hs384", token: sign(t, gojwt.SigningMethodHS384, now, testSecret)},
+		{name: "hs512", token: sign(t, gojwt.SigningMethodHS512, now, testSecret)},
+		{name: "none", token: sign(t, gojwt.SigningMethodNone, now, gojwt.UnsafeAllowNoneSignatureType)},
+		{name: "wrong secret", token: sign(t, gojwt.SigningMethodHS256, now, []byte("fedcba9876543210fedcba9876543210"))},
+		{name: "stale", token: sign(t, gojwt.SigningMethodHS256, now.Add(-2*MaxIssuedAtDrift), testSecret), err: ErrStaleToken},
+		{name: "future", token: sign(t, gojwt.SigningMethodHS256, now.Add(2*MaxIssuedAtDrift), testSecret), err: ErrStaleToken},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claims, err := Verify(test.token, testSecret)
+			if test.valid {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				if claims.ID != "lighthouse" {
+					t.Fatalf("id claim is %q, expected lighthouse", claims.ID)
+				}
+
+				return
+			}
+
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			if test.err != nil && !errors.Is(err, test.err) {
+				t.Fatalf("error is %v, expected %v", err, test.err)
+			}
+		})
+	}
+}
+
+func TestFromAuthorizationHeader(t *testing.T) {
+	tests := []struct {
+		header string
+		token  string
+		err    bool
+	}{
+		{header: "Bearer abc", token: "abc"},
+		{header: "", err: true},
+		{header: "Basic abc", err: true},
+		{header: "Bearer ", err: true},
+	}
+
+	for _, test := range tests {
+		token, err := FromAuthorizationHeader(test.header)
+		if (err != nil) != test.err || token != test.token {
+			t.Errorf("%q returned %q, %v, expected %q", test.header, token, err, test.token)
+		}
+	}
+}
+
+func TestParseSecret(t *testing.T) {
+	tests := []struct {
+		secret string
+		err    bool
+	}{
+		{secret: "0x3031323334353637383961626364656630313233343536373839616263646566"},
+		{secret: "3031323334353637383961626364656630313233343536373839616263646566\n"},
+		{secret: "0x3031", err: true},
+		{secret: "not hex", err: true},
+	}
+
+	for _, test := range tests {
+		secret, err := ParseSecret(test.secret)
+		if (err != nil) != test.err {
+			t.Errorf("%q: unexpected error %v", test.secret, err)
+		}
+
+		if !test.err && string(secret) != string(testSecret) {
+			t.Errorf("%q decoded to %x", test.secret, secret)
+		}
+	}
+}
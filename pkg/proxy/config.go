@@ -0,0 +1,144 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethpandaops/stubbies/pkg/rpc"
+)
+
+const (
+	// ActionStub answers matching requests with the stub logic instead of forwarding them.
+	ActionStub = "stub"
+	// ActionStatus forwards matching requests and rewrites the payload status of the response.
+	ActionStatus = "status"
+)
+
+type Config struct {
+	Enabled bool `yaml:"enabled" default:"false"`
+	// Upstream is the engine api endpoint of the real execution client.
+	Upstream rpc.Config `yaml:",inline"`
+
+	// GenesisTime and SecondsPerSlot map payload timestamps to slots, GenesisTime is only required by overrides
+	// with a slot range.
+	GenesisTime    uint64 `yaml:"genesisTime"`
+	SecondsPerSlot uint64 `yaml:"secondsPerSlot" default:"12"`
+
+	// Overrides are evaluated in order, the first matching override applies.
+	Overrides []OverrideConfig `yaml:"overrides"`
+}
+
+type OverrideConfig struct {
+	// Methods the override applies to. Empty matches all methods.
+	Methods []string `yaml:"methods"`
+	// FromBlock and ToBlock limit the override to an inclusive range of block numbers. Requests without
+	// a known block number (e.g. eth_chainId) never match an override with a range.
+	FromBlock *uint64 `yaml:"fromBlock"`
+	ToBlock   *uint64 `yaml:"toBlock"`
+	// FromSlot and ToSlot limit the override to an inclusive range of slots, derived from the payload
+	// timestamp. Like block ranges they only match newPayload and forkchoiceUpdated requests.
+	FromSlot *uint64 `yaml:"fromSlot"`
+	ToSlot   *uint64 `yaml:"toSlot"`
+	// Action is one of "stub" (default) or "status".
+	Action string `yaml:"action"`
+	// Status is the payload status returned when Action is "status", e.g. "SYNCING".
+	Status string `yaml:"status"`
+}
+
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if err := c.Upstream.Validate(); err != nil {
+		return fmt.Errorf("proxy: %w", err)
+	}
+
+	if c.SecondsPerSlot == 0 {
+		return errors.New("proxy.secondsPerSlot must be greater than 0")
+	}
+
+	for i := range c.Overrides {
+		if err := c.Overrides[i].Validate(); err != nil {
+			return fmt.Errorf("proxy.overrides[%d]: %w", i, err)
+		}
+
+		if c.Overrides[i].hasSlotRange() && c.GenesisTime == 0 {
+			return fmt.Errorf("proxy.overrides[%d]: proxy.genesisTime is required to match on slots", i)
+		}
+	}
+
+	return nil
+}
+
+func (c *OverrideConfig) Validate() error {
+	switch c.Action {
+	case "", ActionStub:
+	case ActionStatus:
+		switch c.Status {
+		case "VALID", "INVALID", "SYNCING", "ACCEPTED", "INVALID_BLOCK_HASH":
+		default:
+			return fmt.Errorf("invalid status %q", c.Status)
+		}
+	default:
+		return fmt.Errorf("invalid action %q", c.Action)
+	}
+
+	if c.FromBlock != nil && c.ToBlock != nil && *c.FromBlock > *c.ToBlock {
+		return errors.New("fromBlock must not be greater than toBlock")
+	}
+
+	if c.FromSlot != nil && c.ToSlot != nil && *c.FromSlot > *c.ToSlot {
+		return errors.New("fromSlot must not be greater than toSlot")
+	}
+
+	return nil
+}
+
+func (c *OverrideConfig) hasSlotRange() bool {
+	return c.FromSlot != nil || c.ToSlot != nil
+}
+
+func (c *OverrideConfig) action() string {
+	if c.Action == "" {
+		return ActionStub
+	}
+
+	return c.Action
+}
+
+func (c *OverrideConfig) matches(method string, pos position) bool {
+	if len(c.Methods) > 0 {
+		found := false
+
+		for _, m := range c.Methods {
+			if m == method {
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if c.FromBlock != nil || c.ToBlock != nil {
+		if !pos.hasNumber || !inRange(pos.number, c.FromBlock, c.ToBlock) {
+			return false
+		}
+	}
+
+	if c.hasSlotRange() {
+		if !pos.hasSlot || !inRange(pos.slot, c.FromSlot, c.ToSlot) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func inRange(value uint64, from, to *uint64) bool {
+	return (from == nil || value >= *from) && (to == nil || value <= *to)
+}
@@ -0,0 +1,224 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethpandaops/stubbies/pkg/execution"
+	"github.com/ethpandaops/stubbies/pkg/rpc"
+	"github.com/sirupsen/logrus"
+)
+
+// Backend forwards requests to a real execution client, answering selected methods and blocks
+// with the stub logic or rewriting their responses.
+type Backend struct {
+	log logrus.FieldLogger
+	cfg Config
+
+	client *rpc.Client
+	stub   *execution.Handler
+}
+
+func NewBackend(log logrus.FieldLogger, conf *Config, stub *execution.Handler) (*Backend, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	client, err := rpc.NewClientFromConfig(&conf.Upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Backend{
+		log:    log.WithField("module", "proxy"),
+		cfg:    *conf,
+		client: client,
+		stub:   stub,
+	}, nil
+}
+
+func (b *Backend) Start(ctx context.Context) error {
+	b.log.WithField("target", b.client.URL()).Info("proxying to execution client")
+
+	return b.stub.Start(ctx)
+}
+
+func (b *Backend) Stop(ctx context.Context) error {
+	return b.stub.Stop(ctx)
+}
+
+func (b *Backend) SubscribeNewHeads() (<-chan *execution.ResultHeader, func()) {
+	return b.stub.SubscribeNewHeads()
+}
+
+func (b *Backend) LastEngineCall() time.Time {
+	return b.stub.LastEngineCall()
+}
+
+func (b *Backend) Request(ctx context.Context, id int, method string, params []*json.RawMessage) (*execution.Response, error) {
+	pos := b.position(method, params)
+	override := b.match(method, pos)
+
+	if override != nil && override.action() == ActionStub {
+		return b.stub.Request(ctx, id, method, params)
+	}
+
+	b.stub.ObserveCall(method, params)
+
+	// Keep the stub storage in sync so block lookups, block ranges and head subscriptions keep working.
+	if err := b.stub.Mirror(method, params); err != nil {
+		b.log.WithError(err).WithField("method", method).Debug("failed to mirror request to stub")
+	}
+
+	resp, err := b.client.Request(ctx, id, method, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if override != nil && override.action() == ActionStatus && resp.Error == nil {
+		if err := rewriteStatus(resp, method, override.Status); err != nil {
+			return nil, err
+		}
+
+		b.log.WithFields(logrus.Fields{
+			"method": method,
+			"number": pos.number,
+			"slot":   pos.slot,
+			"status": override.Status,
+		}).Debug("rewrote payload status")
+	}
+
+	b.stub.ObserveResponse(method, params, resp)
+
+	return resp, nil
+}
+
+func (b *Backend) match(method string, pos position) *OverrideConfig {
+	for i := range b.cfg.Overrides {
+		if b.cfg.Overrides[i].matches(method, pos) {
+			return &b.cfg.Overrides[i]
+		}
+	}
+
+	return nil
+}
+
+// position is the block number and slot of the payload a request refers to.
+type position struct {
+	number, slot       uint64
+	hasNumber, hasSlot bool
+}
+
+func (b *Backend) position(method string, params []*json.RawMessage) position {
+	var pos position
+
+	if len(params) < 1 || params[0] == nil {
+		return pos
+	}
+
+	var number, timestamp string
+
+	switch {
+	case isNewPayload(method):
+		var payload execution.RequestParamsNewPayloadV1
+		if err := json.Unmarshal(*params[0], &payload); err != nil {
+			return pos
+		}
+
+		number, timestamp = payload.BlockNumber, payload.Timestamp
+	case isForkchoiceUpdated(method):
+		var state execution.RequestParamsForkchoiceUpdatedV1
+		if err := json.Unmarshal(*params[0], &state); err != nil {
+			return pos
+		}
+
+		block := b.stub.Storage().GetBlockByHash(state.HeadBlockHash)
+		if block == nil || block.GetHeader() == nil {
+			return pos
+		}
+
+		header := block.GetHeader()
+		number, timestamp = header.Number, header.Timestamp
+	default:
+		return pos
+	}
+
+	pos.number, pos.hasNumber = parseHexUint64(number)
+
+	if ts, ok := parseHexUint64(timestamp); ok && b.cfg.GenesisTime != 0 && ts >= b.cfg.GenesisTime {
+		pos.slot = (ts - b.cfg.GenesisTime) / b.cfg.SecondsPerSlot
+		pos.hasSlot = true
+	}
+
+	return pos
+}
+
+func rewriteStatus(resp *execution.Response, method, status string) error {
+	if !isNewPayload(method) && !isForkchoiceUpdated(method) {
+		return nil
+	}
+
+	raw, ok := resp.Result.(json.RawMessage)
+	if !ok {
+		return nil
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return err
+	}
+
+	payloadStatus := result
+
+	if isForkchoiceUpdated(method) {
+		nested, ok := result["payloadStatus"].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+
+		payloadStatus = nested
+
+		if status != "VALID" {
+			result["payloadId"] = nil
+		}
+	}
+
+	payloadStatus["status"] = status
+
+	if status == "SYNCING" || status == "ACCEPTED" {
+		payloadStatus["latestValidHash"] = nil
+	}
+
+	rewritten, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	resp.Result = json.RawMessage(rewritten)
+
+	return nil
+}
+
+func isNewPayload(method string) bool {
+	return strings.HasPrefix(method, "engine_newPayload")
+}
+
+func isForkchoiceUpdated(method string) bool {
+	return strings.HasPrefix(method, "engine_forkchoiceUpdated")
+}
+
+func parseHexUint64(s string) (uint64, bool) {
+	if !strings.HasPrefix(s, "0x") {
+		return 0, false
+	}
+
+	n, err := strconv.ParseUint(s[2:], 16, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return n, true
+}
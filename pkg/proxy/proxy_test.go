@@ -0,0 +1,371 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/creasty/defaults"
+	"github.com/ethpandaops/stubbies/pkg/execution"
+	"github.com/ethpandaops/stubbies/pkg/jwt"
+	"github.com/ethpandaops/stubbies/pkg/rpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const upstreamSecret = "0x3031323334353637383961626364656630313233343536373839616263646566"
+
+func uint64Ptr(v uint64) *uint64 {
+	return &v
+}
+
+func rawParams(t *testing.T, params ...interface{}) []*json.RawMessage {
+	t.Helper()
+
+	raws := make([]*json.RawMessage, len(params))
+
+	for i, param := range params {
+		data, err := json.Marshal(param)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		raw := json.RawMessage(data)
+		raws[i] = &raw
+	}
+
+	return raws
+}
+
+func payload(number, timestamp, hash, parent string) *execution.RequestParamsNewPayloadV1 {
+	return &execution.RequestParamsNewPayloadV1{
+		BlockNumber:   number,
+		Timestamp:     timestamp,
+		BlockHash:     hash,
+		ParentHash:    parent,
+		FeeRecipient:  "0x0000000000000000000000000000000000000000",
+		StateRoot:     "0x00",
+		ReceiptsRoot:  "0x00",
+		LogsBloom:     "0x00",
+		Random:        "0x00",
+		GasLimit:      "0x1c9c380",
+		GasUsed:       "0x0",
+		ExtraData:     "0x",
+		BaseFeePerGas: "0x7",
+		Transactions:  []string{},
+	}
+}
+
+func TestOverrideMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		override OverrideConfig
+		method   string
+		pos      position
+		matches  bool
+	}{
+		{name: "any method", override: OverrideConfig{}, method: "eth_chainId", matches: true},
+		{name: "listed method", override: OverrideConfig{Methods: []string{"eth_chainId"}}, method: "eth_chainId", matches: true},
+		{name: "other method", override: OverrideConfig{Methods: []string{"eth_chainId"}}, method: "eth_blockNumber", matches: false},
+		{name: "in block range", override: OverrideConfig{FromBlock: uint64Ptr(100), ToBlock: uint64Ptr(110)}, pos: position{number: 110, hasNumber: true}, matches: true},
+		{name: "below block range", override: OverrideConfig{FromBlock: uint64Ptr(100), ToBlock: uint64Ptr(110)}, pos: position{number: 99, hasNumber: true}, matches: false},
+		{name: "open block range", override: OverrideConfig{FromBlock: uint64Ptr(100)}, pos: position{number: 1000, hasNumber: true}, matches: true},
+		{name: "block range without number", override: OverrideConfig{FromBlock: uint64Ptr(100)}, matches: false},
+		{name: "in slot range", override: OverrideConfig{FromSlot: uint64Ptr(100), ToSlot: uint64Ptr(110)}, pos: position{slot: 100, hasSlot: true}, matches: true},
+		{name: "above slot range", override: OverrideConfig{FromSlot: uint64Ptr(100), ToSlot: uint64Ptr(110)}, pos: position{slot: 111, hasSlot: true}, matches: false},
+		{name: "slot range without slot", override: OverrideConfig{ToSlot: uint64Ptr(110)}, pos: position{number: 1, hasNumber: true}, matches: false},
+		{name: "block and slot range", override: OverrideConfig{FromBlock: uint64Ptr(5), FromSlot: uint64Ptr(100)}, pos: position{number: 5, slot: 99, hasNumber: true, hasSlot: true}, matches: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if matches := test.override.matches(test.method, test.pos); matches != test.matches {
+				t.Fatalf("matches returned %v, expected %v", matches, test.matches)
+			}
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		conf Config
+		err  bool
+	}{
+		{name: "disabled", conf: Config{}},
+		{name: "valid", conf: Config{Enabled: true, Upstream: upstream("http://127.0.0.1:8551"), SecondsPerSlot: 12}},
+		{name: "missing target", conf: Config{Enabled: true, SecondsPerSlot: 12}, err: true},
+		{name: "slots without genesis", conf: Config{Enabled: true, Upstream: upstream("http://127.0.0.1:8551"), SecondsPerSlot: 12, Overrides: []OverrideConfig{{FromSlot: uint64Ptr(1)}}}, err: true},
+		{name: "slots with genesis", conf: Config{Enabled: true, Upstream: upstream("http://127.0.0.1:8551"), SecondsPerSlot: 12, GenesisTime: 1606824023, Overrides: []OverrideConfig{{FromSlot: uint64Ptr(1)}}}},
+		{name: "reversed slots", conf: Config{Enabled: true, Upstream: upstream("http://127.0.0.1:8551"), SecondsPerSlot: 12, GenesisTime: 1606824023, Overrides: []OverrideConfig{{FromSlot: uint64Ptr(2), ToSlot: uint64Ptr(1)}}}, err: true},
+		{name: "unknown status", conf: Config{Enabled: true, Upstream: upstream("http://127.0.0.1:8551"), SecondsPerSlot: 12, Overrides: []OverrideConfig{{Action: ActionStatus, Status: "MAYBE"}}}, err: true},
+		{name: "unknown action", conf: Config{Enabled: true, Upstream: upstream("http://127.0.0.1:8551"), SecondsPerSlot: 12, Overrides: []OverrideConfig{{Action: "drop"}}}, err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.conf.Validate(); (err != nil) != test.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestRewriteStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		result   string
+		status   string
+		expected string
+	}{
+		{
+			name:     "newPayload syncing",
+			method:   "engine_newPayloadV3",
+			result:   `{"status":"VALID","latestValidHash":"0xaa","validationError":null}`,
+			status:   "SYNCING",
+			expected: `{"latestValidHash":null,"status":"SYNCING","validationError":null}`,
+		},
+		{
+			name:     "newPayload invalid keeps latest valid hash",
+			method:   "engine_newPayloadV1",
+			result:   `{"status":"VALID","latestValidHash":"0xaa","validationError":null}`,
+			status:   "INVALID",
+			expected: `{"latestValidHash":"0xaa","status":"INVALID","validationError":null}`,
+		},
+		{
+			name:     "forkchoiceUpdated drops payload id",
+			method:   "engine_forkchoiceUpdatedV2",
+			result:   `{"payloadStatus":{"status":"VALID","latestValidHash":"0xaa","validationError":null},"payloadId":"0x01"}`,
+			status:   "SYNCING",
+			expected: `{"payloadId":null,"payloadStatus":{"latestValidHash":null,"status":"SYNCING","validationError":null}}`,
+		},
+		{
+			name:     "other methods are left alone",
+			method:   "eth_chainId",
+			result:   `"0x1"`,
+			status:   "SYNCING",
+			expected: `"0x1"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := &execution.Response{Result: json.RawMessage(test.result)}
+
+			if err := rewriteStatus(resp, test.method, test.status); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := string(resp.Result.(json.RawMessage)); got != test.expected {
+				t.Fatalf("rewritten to %s, expected %s", got, test.expected)
+			}
+		})
+	}
+}
+
+func upstream(target string) rpc.Config {
+	return rpc.Config{Target: target, JWTSecret: upstreamSecret, Timeout: 5 * time.Second}
+}
+
+// newTestBackend returns a proxy to a fake upstream answering every request with VALID, after checking it was
+// signed with the upstream secret.
+func newTestBackend(t *testing.T, conf Config, stubConf func(*execution.Config)) (*Backend, *[]string) {
+	t.Helper()
+
+	secret, err := jwt.ParseSecret(upstreamSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var methods []string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := jwt.FromAuthorizationHeader(r.Header.Get("Authorization"))
+		if err == nil {
+			_, err = jwt.Verify(token, secret)
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+
+		var req struct {
+			ID     int    `json:"id"`
+			Method string `json:"method"`
+		}
+
+		_ = json.Unmarshal(body, &req)
+
+		methods = append(methods, req.Method)
+
+		result := `{"status":"VALID","latestValidHash":"0xaa","validationError":null}`
+		if req.Method == "engine_forkchoiceUpdatedV1" {
+			result = `{"payloadStatus":{"status":"VALID","latestValidHash":"0xaa","validationError":null},"payloadId":null}`
+		}
+
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":` + result + `}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	sc := &execution.Config{}
+	if err := defaults.Set(sc); err != nil {
+		t.Fatal(err)
+	}
+
+	if stubConf != nil {
+		stubConf(sc)
+	}
+
+	stub, err := execution.NewHandler(logrus.New(), sc, prometheus.NewRegistry(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf.Enabled = true
+	conf.Upstream = upstream(srv.URL)
+
+	if conf.SecondsPerSlot == 0 {
+		conf.SecondsPerSlot = 12
+	}
+
+	b, err := NewBackend(logrus.New(), &conf, stub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = b.Stop(context.Background()) })
+
+	return b, &methods
+}
+
+func TestBackendRequest(t *testing.T) {
+	// Genesis at 1000 with 12s slots, the payload at 0x4b0 (1200) is in slot 16.
+	b, methods := newTestBackend(t, Config{
+		GenesisTime: 1000,
+		Overrides: []OverrideConfig{
+			{Methods: []string{"eth_chainId"}, Action: ActionStub},
+			{Methods: []string{"engine_newPayloadV1"}, FromSlot: uint64Ptr(16), ToSlot: uint64Ptr(16), Action: ActionStatus, Status: "SYNCING"},
+		},
+	}, nil)
+
+	ctx := context.Background()
+
+	resp, err := b.Request(ctx, 1, "eth_chainId", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Result != execution.ResultChainID("0x1") {
+		t.Fatalf("eth_chainId returned %v, expected the stub's 0x1", resp.Result)
+	}
+
+	tests := []struct {
+		payload *execution.RequestParamsNewPayloadV1
+		status  string
+	}{
+		{payload: payload("0x1", "0x4a4", "0x01", "0x00"), status: "VALID"},
+		{payload: payload("0x2", "0x4b0", "0x02", "0x01"), status: "SYNCING"},
+		{payload: payload("0x3", "0x4bc", "0x03", "0x02"), status: "VALID"},
+	}
+
+	for _, test := range tests {
+		resp, err := b.Request(ctx, 1, "engine_newPayloadV1", rawParams(t, test.payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var result execution.ResultNewPayloadV1
+		if err := json.Unmarshal(resp.Result.(json.RawMessage), &result); err != nil {
+			t.Fatal(err)
+		}
+
+		if result.Status != test.status {
+			t.Errorf("payload %s returned %s, expected %s", test.payload.BlockNumber, result.Status, test.status)
+		}
+
+		// Every payload forwarded is mirrored to the stub storage, whatever the status returned.
+		if b.stub.Storage().GetBlockByHash(test.payload.BlockHash) == nil {
+			t.Errorf("payload %s was not stored", test.payload.BlockNumber)
+		}
+	}
+
+	if len(*methods) != 3 {
+		t.Fatalf("upstream received %v, expected the 3 payloads only", *methods)
+	}
+}
+
+func TestBackendMirrorSkipsScenario(t *testing.T) {
+	scenario := filepath.Join(t.TempDir(), "scenario.yaml")
+	if err := os.WriteFile(scenario, []byte("steps:\n  - status: INVALID\n    latency: 1h\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := newTestBackend(t, Config{}, func(conf *execution.Config) {
+		conf.Scenario.Path = scenario
+	})
+
+	// The scenario's latency would exceed the deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := b.Request(ctx, 1, "engine_newPayloadV1", rawParams(t, payload("0x1", "0x4a4", "0x01", "0x00"))); err != nil {
+		t.Fatal(err)
+	}
+
+	state := execution.RequestParamsForkchoiceUpdatedV1{HeadBlockHash: "0x01", SafeBlockHash: "0x01", FinalizedBlockHash: "0x00"}
+	if _, err := b.Request(ctx, 1, "engine_forkchoiceUpdatedV1", rawParams(t, state, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	if b.stub.Storage().GetBlockByHash("0x01") == nil {
+		t.Fatal("the payload accepted upstream was not stored")
+	}
+
+	if head := b.stub.Storage().GetForkchoice().HeadBlockHash; head != "0x01" {
+		t.Fatalf("forkchoice head is %s, expected 0x01", head)
+	}
+}
+
+func TestBackendLastEngineCall(t *testing.T) {
+	b, _ := newTestBackend(t, Config{
+		Overrides: []OverrideConfig{{Methods: []string{"engine_exchangeCapabilities"}, Action: ActionStub}},
+	}, nil)
+
+	tests := []struct {
+		method string
+		params []*json.RawMessage
+		engine bool
+	}{
+		{method: "eth_chainId"},
+		{method: "engine_newPayloadV1", params: rawParams(t, payload("0x1", "0x4a4", "0x01", "0x00")), engine: true},
+		{method: "engine_exchangeCapabilities", params: rawParams(t, []string{"engine_newPayloadV1"}), engine: true},
+	}
+
+	for _, test := range tests {
+		last := b.LastEngineCall()
+		before := time.Now()
+
+		if _, err := b.Request(context.Background(), 1, test.method, test.params); err != nil {
+			t.Fatal(err)
+		}
+
+		if stale := b.LastEngineCall().Before(before); stale == test.engine {
+			t.Errorf("%s: last engine call is %v, was %v", test.method, b.LastEngineCall(), last)
+		}
+	}
+}
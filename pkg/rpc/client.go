@@ -0,0 +1,142 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethpandaops/stubbies/pkg/execution"
+	"github.com/ethpandaops/stubbies/pkg/jwt"
+)
+
+// Client is a minimal JSON-RPC over HTTP client that authenticates with the Engine API JWT scheme.
+type Client struct {
+	url    string
+	secret []byte
+	http   *http.Client
+
+	nextID int64
+}
+
+type request struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      int         `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type response struct {
+	ID      int                      `json:"id"`
+	JSONRPC string                   `json:"jsonrpc"`
+	Result  json.RawMessage          `json:"result"`
+	Error   *execution.ResponseError `json:"error"`
+}
+
+// NewClient returns a new Client. secret may be nil to skip authentication.
+func NewClient(url string, secret []byte, timeout time.Duration) *Client {
+	return &Client{
+		url:    url,
+		secret: secret,
+		http: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+// URL returns the endpoint the client talks to.
+func (c *Client) URL() string {
+	return c.url
+}
+
+// Request sends a JSON-RPC request, returning the response with its result left undecoded as json.RawMessage.
+func (c *Client) Request(ctx context.Context, id int, method string, params interface{}) (*execution.Response, error) {
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	body, err := json.Marshal(request{
+		JSONRPC: "2.0",
+		ID:      id,
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if c.secret != nil {
+		token, err := jwt.NewToken(c.secret, "")
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rsp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+
+	data, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s: %s", method, rsp.Status, bytes.TrimSpace(data))
+	}
+
+	var decoded response
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+
+	result := &execution.Response{
+		ID:      decoded.ID,
+		JSONRPC: decoded.JSONRPC,
+		Error:   decoded.Error,
+	}
+
+	if decoded.Result != nil {
+		result.Result = decoded.Result
+	}
+
+	return result, nil
+}
+
+// Call sends a JSON-RPC request and decodes the result into result. JSON-RPC errors are returned as *execution.ResponseError.
+func (c *Client) Call(ctx context.Context, method string, params, result interface{}) error {
+	rsp, err := c.Request(ctx, int(atomic.AddInt64(&c.nextID, 1)), method, params)
+	if err != nil {
+		return err
+	}
+
+	if rsp.Error != nil {
+		return rsp.Error
+	}
+
+	if result == nil || rsp.Result == nil {
+		return nil
+	}
+
+	raw, ok := rsp.Result.(json.RawMessage)
+	if !ok {
+		return fmt.Errorf("unexpected result type %T", rsp.Result)
+	}
+
+	return json.Unmarshal(raw, result)
+}
@@ -0,0 +1,54 @@
+package rpc
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ethpandaops/stubbies/pkg/jwt"
+)
+
+// Config configures a Client talking to an execution client's engine api.
+type Config struct {
+	Target        string        `yaml:"target"`
+	JWTSecret     string        `yaml:"jwtSecret"`
+	JWTSecretFile string        `yaml:"jwtSecretFile"`
+	Timeout       time.Duration `yaml:"timeout" default:"12s"`
+}
+
+func (c *Config) Validate() error {
+	if c.Target == "" {
+		return errors.New("target is required")
+	}
+
+	if c.JWTSecret != "" && c.JWTSecretFile != "" {
+		return errors.New("only one of jwtSecret and jwtSecretFile can be set")
+	}
+
+	return nil
+}
+
+// Secret returns the configured jwt secret, or nil if none is configured.
+func (c *Config) Secret() ([]byte, error) {
+	switch {
+	case c.JWTSecret != "":
+		return jwt.ParseSecret(c.JWTSecret)
+	case c.JWTSecretFile != "":
+		return jwt.LoadSecret(c.JWTSecretFile)
+	}
+
+	return nil, nil
+}
+
+// NewClientFromConfig returns a new Client for the config.
+func NewClientFromConfig(conf *Config) (*Client, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	secret, err := conf.Secret()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewClient(conf.Target, secret, conf.Timeout), nil
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ethpandaops/stubbies/pkg/api"
+)
+
+// registerClients adds /clients, listing the consensus clients seen by each tenant.
+func (s *Server) registerClients(mux *http.ServeMux) {
+	mux.HandleFunc("/clients", func(w http.ResponseWriter, r *http.Request) {
+		clients := make(map[string][]api.ClientState, len(s.tenants))
+
+		for _, t := range s.tenants {
+			clients[t.cfg.Name] = t.http.Clients()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		//nolint:errcheck // the client has gone away if this fails
+		json.NewEncoder(w).Encode(clients)
+	})
+}
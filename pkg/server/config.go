@@ -1,17 +1,244 @@
 package server
 
 import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/creasty/defaults"
+	"github.com/ethpandaops/stubbies/pkg/capture"
+	"github.com/ethpandaops/stubbies/pkg/divergence"
 	"github.com/ethpandaops/stubbies/pkg/execution"
+	"github.com/ethpandaops/stubbies/pkg/jwt"
+	"github.com/ethpandaops/stubbies/pkg/proxy"
+	"github.com/ethpandaops/stubbies/pkg/shadow"
+	"github.com/ethpandaops/stubbies/pkg/webhook"
 )
 
+// DefaultTenant is the name of the tenant configured by the top level config.
+const DefaultTenant = "default"
+
 type Config struct {
 	LoggingLevel string `yaml:"logging" default:"info"`
 	Addr         string `yaml:"addr" default:":8551"`
 	MetricsAddr  string `yaml:"metricsAddr" default:":9090"`
+	// ShutdownTimeout is how long in-flight requests are given to complete on shutdown.
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout" default:"30s"`
+	// IPCPath additionally serves JSON-RPC on a unix socket. Set addr to "" to only serve ipc.
+	IPCPath string `yaml:"ipcPath"`
+
+	TLS        TLSConfig `yaml:"tls"`
+	MetricsTLS TLSConfig `yaml:"metricsTls"`
+
+	// Health configures the /healthz, /readyz and /livez endpoints served on the metrics addr.
+	Health HealthConfig `yaml:"health"`
+
+	// JWTSecret enables engine api authentication when set. Hex encoded, or use JWTSecretFile.
+	JWTSecret     string `yaml:"jwtSecret"`
+	JWTSecretFile string `yaml:"jwtSecretFile"`
+
+	Execution execution.Config `yaml:"execution"`
+	Capture   capture.Config   `yaml:"capture"`
+	Proxy     proxy.Config     `yaml:"proxy"`
+	Shadow    shadow.Config    `yaml:"shadow"`
+
+	// Divergence reports consensus clients sharing the instance that disagree on forkchoice.
+	Divergence divergence.Config `yaml:"divergence"`
+
+	// Webhooks receive notable events, e.g. reorgs or INVALID payloads.
+	Webhooks []webhook.Config `yaml:"webhooks"`
+
+	// Tenants are additional, isolated stubbies instances served by the same process.
+	Tenants []TenantConfig `yaml:"tenants"`
+}
+
+// TenantConfig is an isolated stubbies instance with its own storage, config, jwt secret and metrics.
+type TenantConfig struct {
+	// Name is added as the "tenant" label to all metrics of the tenant.
+	Name string `yaml:"name"`
+	// Addr serves the tenant on its own listener. Defaults to the top level addr.
+	Addr string `yaml:"addr"`
+	// PathPrefix serves the tenant below a path, e.g. "/tenant/a".
+	PathPrefix string `yaml:"pathPrefix"`
+	IPCPath    string `yaml:"ipcPath"`
+	// TLS of the tenant's own listener. Tenants sharing the top level addr use the top level tls config.
+	TLS TLSConfig `yaml:"tls"`
+
+	JWTSecret     string `yaml:"jwtSecret"`
+	JWTSecretFile string `yaml:"jwtSecretFile"`
 
 	Execution execution.Config `yaml:"execution"`
+	Capture   capture.Config   `yaml:"capture"`
+	Proxy     proxy.Config     `yaml:"proxy"`
+	Shadow    shadow.Config    `yaml:"shadow"`
+
+	Divergence divergence.Config `yaml:"divergence"`
+	Webhooks   []webhook.Config  `yaml:"webhooks"`
+}
+
+// UnmarshalYAML applies the config defaults to each tenant, they are not set for slice elements otherwise.
+func (t *TenantConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := defaults.Set(t); err != nil {
+		return err
+	}
+
+	type plain TenantConfig
+
+	return unmarshal((*plain)(t))
 }
 
 func (c *Config) Validate() error {
+	if c.ShutdownTimeout <= 0 {
+		return errors.New("shutdownTimeout must be positive")
+	}
+
+	if err := c.MetricsTLS.Validate(); err != nil {
+		return fmt.Errorf("metrics: %w", err)
+	}
+
+	if err := c.Health.Validate(); err != nil {
+		return err
+	}
+
+	for _, tenant := range c.Tenants {
+		if tenant.TLS.Enabled() && (tenant.Addr == "" || tenant.Addr == c.Addr) {
+			return fmt.Errorf("tenant %s: tls requires the tenant to have its own addr", tenant.Name)
+		}
+	}
+
+	routes := make(map[string]string)
+	ipcPaths := make(map[string]string)
+	capturePaths := make(map[string]string)
+	names := make(map[string]bool)
+
+	for _, tenant := range c.tenants() {
+		if err := tenant.Validate(); err != nil {
+			return fmt.Errorf("tenant %s: %w", tenant.Name, err)
+		}
+
+		if names[tenant.Name] {
+			return fmt.Errorf("duplicate tenant name %s", tenant.Name)
+		}
+
+		names[tenant.Name] = true
+
+		if tenant.Addr != "" {
+			route := tenant.Addr + tenant.PathPrefix
+			if other, exists := routes[route]; exists {
+				return fmt.Errorf("tenant %s uses the same addr and path prefix as tenant %s", tenant.Name, other)
+			}
+
+			routes[route] = tenant.Name
+		}
+
+		if tenant.IPCPath != "" {
+			if other, exists := ipcPaths[tenant.IPCPath]; exists {
+				return fmt.Errorf("tenant %s uses the same ipc path as tenant %s", tenant.Name, other)
+			}
+
+			ipcPaths[tenant.IPCPath] = tenant.Name
+		}
+
+		if tenant.Capture.Enabled {
+			path := filepath.Clean(tenant.Capture.Path)
+			if other, exists := capturePaths[path]; exists {
+				return fmt.Errorf("tenant %s uses the same capture path as tenant %s", tenant.Name, other)
+			}
+
+			capturePaths[path] = tenant.Name
+		}
+	}
+
 	return nil
 }
+
+func (c *TenantConfig) Validate() error {
+	if c.Name == "" {
+		return errors.New("name is required")
+	}
+
+	if c.Addr == "" && c.IPCPath == "" {
+		return errors.New("one of addr or ipcPath is required")
+	}
+
+	if c.PathPrefix != "" && (!strings.HasPrefix(c.PathPrefix, "/") || strings.HasSuffix(c.PathPrefix, "/")) {
+		return errors.New("pathPrefix must start and must not end with a slash")
+	}
+
+	if c.JWTSecret != "" && c.JWTSecretFile != "" {
+		return errors.New("only one of jwtSecret and jwtSecretFile can be set")
+	}
+
+	if err := c.TLS.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Capture.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Proxy.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Shadow.Validate(); err != nil {
+		return err
+	}
+
+	if c.Proxy.Enabled && c.Shadow.Enabled {
+		return errors.New("proxy and shadow modes can not be enabled at the same time")
+	}
+
+	if err := c.Divergence.Validate(); err != nil {
+		return err
+	}
+
+	for i := range c.Webhooks {
+		if err := c.Webhooks[i].Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *TenantConfig) jwtSecret() ([]byte, error) {
+	switch {
+	case c.JWTSecret != "":
+		return jwt.ParseSecret(c.JWTSecret)
+	case c.JWTSecretFile != "":
+		return jwt.LoadSecret(c.JWTSecretFile)
+	}
+
+	return nil, nil
+}
+
+// tenants returns the default tenant described by the top level config followed by the configured tenants.
+func (c *Config) tenants() []TenantConfig {
+	tenants := []TenantConfig{{
+		Name:          DefaultTenant,
+		Addr:          c.Addr,
+		IPCPath:       c.IPCPath,
+		TLS:           c.TLS,
+		JWTSecret:     c.JWTSecret,
+		JWTSecretFile: c.JWTSecretFile,
+		Execution:     c.Execution,
+		Capture:       c.Capture,
+		Proxy:         c.Proxy,
+		Shadow:        c.Shadow,
+		Divergence:    c.Divergence,
+		Webhooks:      c.Webhooks,
+	}}
+
+	for _, tenant := range c.Tenants {
+		if tenant.Addr == "" {
+			tenant.Addr = c.Addr
+		}
+
+		tenants = append(tenants, tenant)
+	}
+
+	return tenants
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethpandaops/stubbies/pkg/api"
+)
+
+// eventsKeepAlive is the interval of comments keeping idle event streams from being closed by proxies.
+const eventsKeepAlive = 15 * time.Second
+
+// registerEvents adds /events, a server-sent events stream of handled engine api calls. The stream can
+// be filtered with comma separated tenant, method (prefix) and client query parameters.
+func (s *Server) registerEvents(mux *http.ServeMux) {
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+
+			return
+		}
+
+		query := r.URL.Query()
+		filter := api.ActivityFilter{
+			Methods: splitQuery(query.Get("method")),
+			Clients: splitQuery(query.Get("client")),
+		}
+
+		tenants := make(map[string]bool)
+		for _, name := range splitQuery(query.Get("tenant")) {
+			tenants[name] = true
+		}
+
+		activity := make(chan api.Activity, 256)
+		// closed receives once for every tenant feed closed on shutdown.
+		closed := make(chan struct{}, len(s.tenants))
+		done := make(chan struct{})
+
+		defer close(done)
+
+		subscribed := 0
+
+		for _, t := range s.tenants {
+			if len(tenants) > 0 && !tenants[t.cfg.Name] {
+				continue
+			}
+
+			ch, unsubscribe := t.http.SubscribeActivity(filter)
+			defer unsubscribe()
+
+			subscribed++
+
+			go forwardActivity(t.cfg.Name, ch, activity, closed, done)
+		}
+
+		if subscribed == 0 {
+			http.Error(w, "unknown tenant", http.StatusNotFound)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(eventsKeepAlive)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-closed:
+				return
+			case <-keepAlive.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+			case a := <-activity:
+				data, err := json.Marshal(a)
+				if err != nil {
+					s.log.WithError(err).Error("Failed to marshal activity")
+
+					continue
+				}
+
+				if _, err := fmt.Fprintf(w, "event: engine_call\ndata: %s\n\n", data); err != nil {
+					return
+				}
+			}
+
+			flusher.Flush()
+		}
+	})
+}
+
+// forwardActivity copies a tenant's activity into out until done, signalling closed once the tenant's feed is closed.
+// Activity is dropped rather than blocking the feed when the stream falls behind.
+func forwardActivity(tenant string, in <-chan *api.Activity, out chan<- api.Activity, closed chan<- struct{}, done <-chan struct{}) {
+	for a := range in {
+		activity := *a
+		activity.Tenant = tenant
+
+		select {
+		case <-done:
+			return
+		case out <- activity:
+		default:
+		}
+	}
+
+	closed <- struct{}{}
+}
+
+func splitQuery(value string) []string {
+	if value == "" {
+		return nil
+	}
+
+	parts := strings.Split(value, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+
+	return parts
+}
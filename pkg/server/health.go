@@ -0,0 +1,123 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethpandaops/stubbies/pkg/health"
+)
+
+type HealthConfig struct {
+	// ConsensusClientTimeout reports /healthz unhealthy when no engine api call arrived for this long. 0 disables the check.
+	ConsensusClientTimeout time.Duration `yaml:"consensusClientTimeout" default:"0s"`
+}
+
+func (c *HealthConfig) Validate() error {
+	if c.ConsensusClientTimeout < 0 {
+		return errors.New("health.consensusClientTimeout must be positive")
+	}
+
+	return nil
+}
+
+const (
+	healthOK        = health.StatusOK
+	healthUnhealthy = health.StatusUnhealthy
+)
+
+// registerHealth adds the health endpoints:
+//   - /livez: the process is up and serving
+//   - /readyz: all listeners are up and all tenants are started
+//   - /healthz: ready, and (optionally) a consensus client called the engine api recently
+func (s *Server) registerHealth(mux *http.ServeMux) {
+	mux.HandleFunc("/livez", func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, map[string]string{})
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, s.readinessChecks())
+	})
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		checks := s.readinessChecks()
+
+		for name, check := range s.consensusClientChecks() {
+			checks[name] = check
+		}
+
+		writeHealth(w, checks)
+	})
+}
+
+func (s *Server) readinessChecks() map[string]string {
+	checks := map[string]string{
+		"listeners": healthOK,
+	}
+
+	if atomic.LoadInt32(&s.ready) == 0 {
+		checks["listeners"] = "not listening"
+	}
+
+	for _, t := range s.tenants {
+		check := healthOK
+		if !t.http.Started() {
+			check = "not started"
+		}
+
+		checks[fmt.Sprintf("tenant/%s/storage", t.cfg.Name)] = check
+	}
+
+	return checks
+}
+
+func (s *Server) consensusClientChecks() map[string]string {
+	checks := make(map[string]string)
+
+	timeout := s.Cfg.Health.ConsensusClientTimeout
+	if timeout == 0 {
+		return checks
+	}
+
+	for _, t := range s.tenants {
+		name := fmt.Sprintf("tenant/%s/consensus_client", t.cfg.Name)
+
+		last := t.http.LastEngineCall()
+
+		switch {
+		case last.IsZero():
+			checks[name] = "no engine api call received"
+		case time.Since(last) > timeout:
+			checks[name] = fmt.Sprintf("last engine api call %s ago", time.Since(last).Round(time.Second))
+		default:
+			checks[name] = healthOK
+		}
+	}
+
+	return checks
+}
+
+func writeHealth(w http.ResponseWriter, checks map[string]string) {
+	rsp := health.Response{
+		Status: healthOK,
+		Checks: checks,
+	}
+
+	for _, check := range checks {
+		if check != healthOK {
+			rsp.Status = healthUnhealthy
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if rsp.Status != healthOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	//nolint:errcheck // the client has gone away if this fails
+	json.NewEncoder(w).Encode(rsp)
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+func listenIPC(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o751); err != nil {
+		return nil, err
+	}
+
+	// Remove a stale socket left behind by a previous run.
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(path, 0o600); err != nil {
+		listener.Close()
+
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+func (t *tenant) serveIPC(ctx context.Context, listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+
+		go t.http.ServeIPC(ctx, conn, t.cfg.IPCPath)
+	}
+}
@@ -2,11 +2,16 @@ package server
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"sync/atomic"
 	"time"
 
-	"github.com/ethpandaops/stubbies/pkg/api"
+	exec "github.com/ethpandaops/stubbies/pkg/execution"
 	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 )
@@ -15,71 +20,323 @@ type Server struct {
 	log *logrus.Logger
 	Cfg Config
 
-	http *api.Handler
+	tenants []*tenant
+
+	// Tenants sharing an addr share a listener and are routed by path prefix.
+	addrs      []string
+	routers    map[string]*httprouter.Router
+	tlsConfigs map[string]*TLSConfig
+	// listenAddrs maps configured addrs to the addrs actually listened on, e.g. for ":0".
+	listenAddrs       map[string]string
+	metricsListenAddr string
+
+	gatherer prometheus.Gatherer
+
+	servers       []*http.Server
+	ipcListeners  []net.Listener
+	metricsServer *http.Server
+	errs          chan error
+
+	ready int32
+}
+
+// NewServer returns a server registering its metrics with the default prometheus registry.
+func NewServer(log *logrus.Logger, conf *Config) (*Server, error) {
+	return newServer(log, conf, prometheus.DefaultRegisterer, prometheus.DefaultGatherer)
+}
+
+// NewServerWithRegistry returns a server registering its metrics with reg, and serving only those. Use it to
+// run several servers in one process.
+func NewServerWithRegistry(log *logrus.Logger, conf *Config, reg *prometheus.Registry) (*Server, error) {
+	return newServer(log, conf, reg, reg)
 }
 
-func NewServer(log *logrus.Logger, conf *Config) *Server {
+func newServer(log *logrus.Logger, conf *Config, reg prometheus.Registerer, gatherer prometheus.Gatherer) (*Server, error) {
 	if err := conf.Validate(); err != nil {
-		log.Fatalf("invalid config: %s", err)
+		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 
 	s := &Server{
-		Cfg: *conf,
-		log: log,
+		Cfg:         *conf,
+		log:         log,
+		routers:     make(map[string]*httprouter.Router),
+		tlsConfigs:  make(map[string]*TLSConfig),
+		listenAddrs: make(map[string]string),
+		gatherer:    gatherer,
+	}
+
+	for _, tenantConf := range conf.tenants() {
+		tenantConf := tenantConf
+
+		t, err := newTenant(log, &tenantConf, reg)
+		if err != nil {
+			for _, created := range s.tenants {
+				created.discard()
+			}
+
+			return nil, fmt.Errorf("failed to create tenant %s: %w", tenantConf.Name, err)
+		}
+
+		s.tenants = append(s.tenants, t)
 
-		http: api.NewHandler(log, &conf.Execution),
+		if t.cfg.Addr != "" {
+			if _, exists := s.routers[t.cfg.Addr]; !exists {
+				s.routers[t.cfg.Addr] = httprouter.New()
+				s.tlsConfigs[t.cfg.Addr] = &t.cfg.TLS
+				s.addrs = append(s.addrs, t.cfg.Addr)
+			}
+		}
 	}
 
-	return s
+	s.errs = make(chan error, len(s.tenants)*2+1)
+
+	return s, nil
 }
 
+// Start serves until ctx is cancelled or a listener fails, then shuts down gracefully: in-flight requests are
+// drained for up to the configured shutdown timeout before the tenants are stopped.
 func (s *Server) Start(ctx context.Context) error {
-	s.log.Infof("starting stubbies server")
+	err := s.Listen(ctx)
+	if err == nil {
+		select {
+		case <-ctx.Done():
+			s.log.Info("shutting down")
+		case err = <-s.errs:
+			s.log.WithError(err).Error("listener failed, shutting down")
+		}
+	}
 
-	s.http.Start(ctx)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.Cfg.ShutdownTimeout)
+	defer cancel()
 
-	router := httprouter.New()
+	if shutdownErr := s.Shutdown(shutdownCtx); shutdownErr != nil && err == nil {
+		err = shutdownErr
+	}
 
-	if err := s.http.Register(ctx, router); err != nil {
+	return err
+}
+
+// Listen starts the tenants and all listeners without blocking. The server has to be stopped with Shutdown,
+// also when Listen fails.
+func (s *Server) Listen(ctx context.Context) error {
+	s.log.Infof("starting stubbies server")
+
+	if err := s.listen(ctx, s.errs); err != nil {
 		return err
 	}
 
-	if err := s.ServeMetrics(ctx); err != nil {
+	atomic.StoreInt32(&s.ready, 1)
+
+	return nil
+}
+
+func (s *Server) listen(ctx context.Context, errs chan<- error) error {
+	for _, t := range s.tenants {
+		router, exists := s.routers[t.cfg.Addr]
+		if !exists {
+			// Tenants only served over ipc still need a router to register on.
+			router = httprouter.New()
+		}
+
+		if err := t.Start(ctx, router); err != nil {
+			return err
+		}
+	}
+
+	if err := s.ServeMetrics(ctx, errs); err != nil {
 		return err
 	}
 
-	server := &http.Server{
-		Addr:              s.Cfg.Addr,
-		ReadHeaderTimeout: 3 * time.Minute,
-		WriteTimeout:      15 * time.Minute,
+	for _, t := range s.tenants {
+		if t.cfg.IPCPath == "" {
+			continue
+		}
+
+		listener, err := listenIPC(t.cfg.IPCPath)
+		if err != nil {
+			return err
+		}
+
+		s.ipcListeners = append(s.ipcListeners, listener)
+
+		s.log.Infof("serving ipc at %s", t.cfg.IPCPath)
+
+		go func(t *tenant) {
+			// ipc connections are closed when the tenant stops, not when ctx is cancelled, so they can drain.
+			if err := t.serveIPC(context.Background(), listener); err != nil && !errors.Is(err, net.ErrClosed) {
+				errs <- err
+			}
+		}(t)
 	}
 
-	server.Handler = router
+	for _, addr := range s.addrs {
+		server := &http.Server{
+			Addr:              addr,
+			ReadHeaderTimeout: 3 * time.Minute,
+			WriteTimeout:      15 * time.Minute,
+		}
+
+		server.Handler = s.routers[addr]
+
+		if err := s.configureTLS(server, s.tlsConfigs[addr]); err != nil {
+			return err
+		}
+
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+
+		s.servers = append(s.servers, server)
+		s.listenAddrs[addr] = listener.Addr().String()
 
-	s.log.Infof("serving http at %s", s.Cfg.Addr)
+		s.log.Infof("serving http at %s", listener.Addr())
 
-	if err := server.ListenAndServe(); err != nil {
-		s.log.Fatal(err)
+		go func() {
+			if err := serve(server, listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errs <- err
+			}
+		}()
 	}
 
 	return nil
 }
 
-func (s *Server) ServeMetrics(ctx context.Context) error {
-	go func() {
-		server := &http.Server{
-			Addr:              s.Cfg.MetricsAddr,
-			ReadHeaderTimeout: 15 * time.Second,
+// Shutdown drains the listeners and stops the tenants.
+func (s *Server) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&s.ready, 0)
+
+	var err error
+
+	for _, server := range s.servers {
+		if shutdownErr := server.Shutdown(ctx); shutdownErr != nil {
+			s.log.WithError(shutdownErr).WithField("addr", server.Addr).Error("failed to drain http listener")
+
+			err = shutdownErr
+		}
+	}
+
+	for _, listener := range s.ipcListeners {
+		listener.Close()
+	}
+
+	for _, t := range s.tenants {
+		if stopErr := t.Stop(ctx); stopErr != nil {
+			s.log.WithError(stopErr).WithField("tenant", t.cfg.Name).Error("failed to stop tenant")
+
+			err = stopErr
+		}
+	}
+
+	if s.metricsServer != nil {
+		if shutdownErr := s.metricsServer.Shutdown(ctx); shutdownErr != nil {
+			err = shutdownErr
 		}
+	}
 
-		server.Handler = promhttp.Handler()
+	s.log.Info("stopped stubbies server")
+
+	return err
+}
+
+func (s *Server) ServeMetrics(ctx context.Context, errs chan<- error) error {
+	server := &http.Server{
+		Addr:              s.Cfg.MetricsAddr,
+		ReadHeaderTimeout: 15 * time.Second,
+	}
 
-		s.log.Infof("serving metrics at %s", s.Cfg.MetricsAddr)
+	mux := http.NewServeMux()
+	mux.Handle("/", s.metricsHandler())
+	s.registerHealth(mux)
+	s.registerClients(mux)
+	s.registerEvents(mux)
 
-		if err := server.ListenAndServe(); err != nil {
-			s.log.Fatal(err)
+	server.Handler = mux
+
+	if err := s.configureTLS(server, &s.Cfg.MetricsTLS); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", s.Cfg.MetricsAddr)
+	if err != nil {
+		return err
+	}
+
+	s.metricsServer = server
+	s.metricsListenAddr = listener.Addr().String()
+
+	s.log.Infof("serving metrics at %s", listener.Addr())
+
+	go func() {
+		if err := serve(server, listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errs <- err
 		}
 	}()
 
 	return nil
 }
+
+func (s *Server) metricsHandler() http.Handler {
+	if s.gatherer == prometheus.DefaultGatherer {
+		return promhttp.Handler()
+	}
+
+	return promhttp.HandlerFor(s.gatherer, promhttp.HandlerOpts{})
+}
+
+// Addr returns the address the top level addr listens on, e.g. with the port chosen for ":0". It is empty
+// before Listen.
+func (s *Server) Addr() string {
+	return s.listenAddrs[s.Cfg.Addr]
+}
+
+// MetricsAddr returns the address the metrics listen on. It is empty before Listen.
+func (s *Server) MetricsAddr() string {
+	return s.metricsListenAddr
+}
+
+// Handler returns the handler serving the tenants of the top level addr, e.g. for httptest. Their routes are
+// registered by Listen. It is nil when the top level addr is unset.
+func (s *Server) Handler() http.Handler {
+	router, exists := s.routers[s.Cfg.Addr]
+	if !exists {
+		return nil
+	}
+
+	return router
+}
+
+// Execution returns the execution handler of a tenant, to inspect its storage or change its behaviour.
+func (s *Server) Execution(tenant string) (*exec.Handler, bool) {
+	for _, t := range s.tenants {
+		if t.cfg.Name == tenant {
+			return t.stub, true
+		}
+	}
+
+	return nil, false
+}
+
+func (s *Server) configureTLS(server *http.Server, conf *TLSConfig) error {
+	if !conf.Enabled() {
+		return nil
+	}
+
+	reloader, err := newCertReloader(s.log, conf)
+	if err != nil {
+		return err
+	}
+
+	server.TLSConfig = reloader.TLSConfig()
+
+	return nil
+}
+
+func serve(server *http.Server, listener net.Listener) error {
+	if server.TLSConfig != nil {
+		// The certificates are served by the tls config.
+		return server.ServeTLS(listener, "", "")
+	}
+
+	return server.Serve(listener)
+}
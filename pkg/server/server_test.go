@@ -0,0 +1,78 @@
+package server
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/creasty/defaults"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+func newTestConfig(t *testing.T, tenants ...string) *Config {
+	t.Helper()
+
+	conf := &Config{}
+	if err := defaults.Set(conf); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range tenants {
+		tenant := TenantConfig{}
+		if err := defaults.Set(&tenant); err != nil {
+			t.Fatal(err)
+		}
+
+		tenant.Name = name
+		tenant.PathPrefix = "/" + name
+
+		conf.Tenants = append(conf.Tenants, tenant)
+	}
+
+	return conf
+}
+
+func TestNewServerDiscardsTenants(t *testing.T) {
+	tests := []struct {
+		name string
+		// failing is the index of the tenant failing to be created.
+		failing int
+	}{
+		{name: "default tenant fails", failing: -1},
+		{name: "first tenant fails", failing: 0},
+		{name: "last tenant fails", failing: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			conf := newTestConfig(t, "a", "b")
+
+			capture := &conf.Capture
+			if test.failing >= 0 {
+				capture = &conf.Tenants[test.failing].Capture
+			}
+
+			capture.Enabled = true
+			capture.Path = filepath.Join(t.TempDir(), "missing", "capture.jsonl")
+
+			if _, err := NewServerWithRegistry(logrus.New(), conf, reg); err == nil {
+				t.Fatal("expected an error")
+			}
+
+			families, err := reg.Gather()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(families) != 0 {
+				t.Fatalf("%d metric families are still registered, e.g. %s", len(families), families[0].GetName())
+			}
+
+			// Registering the same tenants again panics if the discarded tenants left collectors behind.
+			if _, err := NewServerWithRegistry(logrus.New(), newTestConfig(t, "a", "b"), reg); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
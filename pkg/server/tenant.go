@@ -0,0 +1,212 @@
+package server
+
+import (
+	"context"
+
+	"github.com/ethpandaops/stubbies/pkg/api"
+	"github.com/ethpandaops/stubbies/pkg/capture"
+	"github.com/ethpandaops/stubbies/pkg/divergence"
+	exec "github.com/ethpandaops/stubbies/pkg/execution"
+	"github.com/ethpandaops/stubbies/pkg/proxy"
+	"github.com/ethpandaops/stubbies/pkg/shadow"
+	"github.com/ethpandaops/stubbies/pkg/webhook"
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+type tenant struct {
+	cfg TenantConfig
+
+	stub       *exec.Handler
+	http       *api.Handler
+	capture    *capture.Writer
+	divergence *divergence.Monitor
+	webhooks   *webhook.Dispatcher
+
+	reg *tenantRegisterer
+}
+
+// tenantRegisterer records the collectors registered by a tenant, to unregister them when it is discarded.
+type tenantRegisterer struct {
+	prometheus.Registerer
+
+	collectors []prometheus.Collector
+}
+
+func (r *tenantRegisterer) Register(c prometheus.Collector) error {
+	if err := r.Registerer.Register(c); err != nil {
+		return err
+	}
+
+	r.collectors = append(r.collectors, c)
+
+	return nil
+}
+
+func (r *tenantRegisterer) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (r *tenantRegisterer) unregisterAll() {
+	for _, c := range r.collectors {
+		r.Registerer.Unregister(c)
+	}
+
+	r.collectors = nil
+}
+
+func newTenant(log logrus.FieldLogger, conf *TenantConfig, reg prometheus.Registerer) (*tenant, error) {
+	t := &tenant{
+		cfg: *conf,
+		reg: &tenantRegisterer{Registerer: prometheus.WrapRegistererWith(prometheus.Labels{"tenant": conf.Name}, reg)},
+	}
+
+	if err := t.init(log.WithField("tenant", conf.Name), conf); err != nil {
+		t.discard()
+
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (t *tenant) init(log logrus.FieldLogger, conf *TenantConfig) error {
+	reg := t.reg
+
+	if conf.Capture.Enabled {
+		w, err := capture.NewWriter(log, &conf.Capture)
+		if err != nil {
+			return err
+		}
+
+		t.capture = w
+	}
+
+	secret, err := conf.jwtSecret()
+	if err != nil {
+		return err
+	}
+
+	t.webhooks, err = webhook.NewDispatcher(log, conf.Webhooks, conf.Name, reg)
+	if err != nil {
+		return err
+	}
+
+	stub, err := exec.NewHandler(log, &conf.Execution, reg, t.webhooks)
+	if err != nil {
+		return err
+	}
+
+	t.stub = stub
+
+	var backend exec.Backend = stub
+
+	if conf.Proxy.Enabled {
+		b, err := proxy.NewBackend(log, &conf.Proxy, stub)
+		if err != nil {
+			return err
+		}
+
+		backend = b
+	}
+
+	if conf.Shadow.Enabled {
+		b, err := shadow.NewBackend(log, &conf.Shadow, stub, reg)
+		if err != nil {
+			return err
+		}
+
+		backend = b
+	}
+
+	t.http = api.NewHandler(log, backend, reg, t.capture, secret)
+
+	if conf.Divergence.Enabled {
+		m, err := divergence.NewMonitor(log, &conf.Divergence, t.forkchoiceViews, blockNumberFunc(stub), t.webhooks, reg)
+		if err != nil {
+			return err
+		}
+
+		t.divergence = m
+	}
+
+	return nil
+}
+
+// forkchoiceViews returns the latest forkchoice state of every consensus client that sent one.
+func (t *tenant) forkchoiceViews() []divergence.View {
+	var views []divergence.View
+
+	for _, client := range t.http.Clients() {
+		if client.LastForkchoiceUpdate.IsZero() {
+			continue
+		}
+
+		views = append(views, divergence.View{
+			Client:    client.ID,
+			Head:      client.LastHead,
+			Finalized: client.LastFinalized,
+			UpdatedAt: client.LastForkchoiceUpdate,
+		})
+	}
+
+	return views
+}
+
+func blockNumberFunc(stub *exec.Handler) divergence.BlockNumberFunc {
+	return func(hash string) (uint64, bool) {
+		block := stub.Storage().GetBlockByHash(hash)
+		if block == nil {
+			return 0, false
+		}
+
+		return block.Number.Uint64(), true
+	}
+}
+
+func (t *tenant) Start(ctx context.Context, router *httprouter.Router) error {
+	t.webhooks.Start(ctx)
+
+	if err := t.http.Start(ctx); err != nil {
+		return err
+	}
+
+	if t.divergence != nil {
+		t.divergence.Start(ctx)
+	}
+
+	return t.http.Register(ctx, router, t.cfg.PathPrefix)
+}
+
+// Stop stops the tenant and flushes its capture file. Listeners must be drained before.
+func (t *tenant) Stop(ctx context.Context) error {
+	if t.divergence != nil {
+		t.divergence.Stop()
+	}
+
+	err := t.http.Stop(ctx)
+
+	t.webhooks.Stop()
+
+	if t.capture != nil {
+		if closeErr := t.capture.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+// discard releases a tenant that was never started: its capture file and its metrics.
+func (t *tenant) discard() {
+	if t.capture != nil {
+		_ = t.capture.Close()
+	}
+
+	t.reg.unregisterAll()
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type TLSConfig struct {
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+	// ClientCAFile enables mutual tls, requiring clients to present a certificate signed by one of its CAs.
+	ClientCAFile string `yaml:"clientCaFile"`
+}
+
+func (c *TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+func (c *TLSConfig) Validate() error {
+	if !c.Enabled() {
+		if c.ClientCAFile != "" {
+			return errors.New("tls.clientCaFile requires tls.certFile and tls.keyFile")
+		}
+
+		return nil
+	}
+
+	if c.CertFile == "" || c.KeyFile == "" {
+		return errors.New("both tls.certFile and tls.keyFile are required")
+	}
+
+	return nil
+}
+
+// certReloader serves the certificate and client CAs of a TLSConfig, reloading them when the files change on disk.
+type certReloader struct {
+	log logrus.FieldLogger
+	cfg TLSConfig
+
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time
+
+	mu sync.Mutex
+}
+
+func newCertReloader(log logrus.FieldLogger, conf *TLSConfig) (*certReloader, error) {
+	r := &certReloader{
+		log:      log.WithField("module", "tls"),
+		cfg:      *conf,
+		modTimes: make(map[string]time.Time),
+	}
+
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// TLSConfig returns a tls.Config that picks up certificate changes on the next handshake.
+func (r *certReloader) TLSConfig() *tls.Config {
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+
+	conf := base.Clone()
+	conf.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return r.certificate(), nil
+	}
+	conf.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		if err := r.reloadIfChanged(); err != nil {
+			r.log.WithError(err).Error("Failed to reload certificates, serving the previous ones")
+		}
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		conf := base.Clone()
+		conf.Certificates = []tls.Certificate{*r.cert}
+
+		if r.clientCAs != nil {
+			conf.ClientCAs = r.clientCAs
+			conf.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+
+		return conf, nil
+	}
+
+	return conf
+}
+
+func (r *certReloader) certificate() *tls.Certificate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.cert
+}
+
+func (r *certReloader) files() []string {
+	files := []string{r.cfg.CertFile, r.cfg.KeyFile}
+	if r.cfg.ClientCAFile != "" {
+		files = append(files, r.cfg.ClientCAFile)
+	}
+
+	return files
+}
+
+func (r *certReloader) reloadIfChanged() error {
+	changed := false
+
+	r.mu.Lock()
+
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			r.mu.Unlock()
+
+			return err
+		}
+
+		if !info.ModTime().Equal(r.modTimes[file]) {
+			changed = true
+		}
+	}
+
+	r.mu.Unlock()
+
+	if !changed {
+		return nil
+	}
+
+	if err := r.reload(); err != nil {
+		return err
+	}
+
+	r.log.WithField("cert", r.cfg.CertFile).Info("reloaded tls certificates")
+
+	return nil
+}
+
+func (r *certReloader) reload() error {
+	modTimes := make(map[string]time.Time)
+
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+
+		modTimes[file] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	var clientCAs *x509.CertPool
+
+	if r.cfg.ClientCAFile != "" {
+		data, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return err
+		}
+
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no certificates found in %s", r.cfg.ClientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.modTimes = modTimes
+
+	return nil
+}
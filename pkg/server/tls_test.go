@@ -0,0 +1,196 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// writeCert writes a self-signed certificate for 127.0.0.1 and its key, returning the certificate.
+func writeCert(t *testing.T, certFile, keyFile, name string) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Make sure the reloader sees a new modification time.
+	later := time.Now().Add(time.Duration(len(name)) * time.Second)
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.Chtimes(file, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+// serveTLS serves a handler answering with the protocol of the request, as the server does.
+func serveTLS(t *testing.T, conf *TLSConfig) string {
+	t.Helper()
+
+	reloader, err := newCertReloader(logrus.New(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &http.Server{
+		ReadHeaderTimeout: time.Second,
+		TLSConfig:         reloader.TLSConfig(),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(r.Proto))
+		}),
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() { _ = serve(server, listener) }()
+
+	t.Cleanup(func() { server.Close() })
+
+	return "https://" + listener.Addr().String()
+}
+
+// get requests url with a fresh connection, returning the certificate served and the protocol negotiated.
+func get(url string, clientConf *tls.Config) (*x509.Certificate, string, error) {
+	transport := &http.Transport{TLSClientConfig: clientConf, ForceAttemptHTTP2: true}
+	defer transport.CloseIdleConnections()
+
+	rsp, err := (&http.Client{Transport: transport, Timeout: 5 * time.Second}).Get(url)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rsp.Body.Close()
+
+	return rsp.TLS.PeerCertificates[0], rsp.Proto, nil
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+
+	first := writeCert(t, certFile, keyFile, "first")
+	url := serveTLS(t, &TLSConfig{CertFile: certFile, KeyFile: keyFile})
+
+	tests := []struct {
+		name     string
+		rotate   bool
+		protocol string
+	}{
+		{name: "serves the certificate over h2", protocol: "HTTP/2.0"},
+		{name: "reloads a changed certificate", rotate: true, protocol: "HTTP/2.0"},
+	}
+
+	expected := first
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.rotate {
+				expected = writeCert(t, certFile, keyFile, "second")
+			}
+
+			cert, protocol, err := get(url, &tls.Config{InsecureSkipVerify: true}) //nolint:gosec // self-signed
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !cert.Equal(expected) {
+				t.Fatalf("served %s, expected %s", cert.Subject.CommonName, expected.Subject.CommonName)
+			}
+
+			if protocol != test.protocol {
+				t.Fatalf("negotiated %s, expected %s", protocol, test.protocol)
+			}
+		})
+	}
+}
+
+func TestCertReloaderClientCA(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	clientCertFile, clientKeyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+
+	writeCert(t, certFile, keyFile, "server")
+	writeCert(t, clientCertFile, clientKeyFile, "client")
+
+	url := serveTLS(t, &TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: clientCertFile})
+
+	clientCert, err := tls.LoadX509KeyPair(clientCertFile, clientKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		certs []tls.Certificate
+		err   bool
+	}{
+		{name: "client certificate", certs: []tls.Certificate{clientCert}},
+		{name: "no client certificate", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, protocol, err := get(url, &tls.Config{InsecureSkipVerify: true, Certificates: test.certs}) //nolint:gosec // self-signed
+			if (err != nil) != test.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !test.err && protocol != "HTTP/2.0" {
+				t.Fatalf("negotiated %s, expected HTTP/2.0", protocol)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package shadow
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethpandaops/stubbies/pkg/rpc"
+)
+
+type Config struct {
+	Enabled bool `yaml:"enabled" default:"false"`
+	// Upstream is the engine api endpoint of the execution client to compare against.
+	Upstream rpc.Config `yaml:",inline"`
+	// QueueSize is the number of requests buffered for the upstream. Requests are dropped when the queue is full.
+	QueueSize int `yaml:"queueSize" default:"1000"`
+	// IgnoreFields are result fields that are expected to differ and are never reported, e.g. "payloadId".
+	IgnoreFields []string `yaml:"ignoreFields" default:"[\"payloadId\",\"validationError\"]"`
+}
+
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if err := c.Upstream.Validate(); err != nil {
+		return fmt.Errorf("shadow: %w", err)
+	}
+
+	if c.QueueSize < 1 {
+		return errors.New("shadow.queueSize must be at least 1")
+	}
+
+	return nil
+}
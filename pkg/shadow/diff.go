@@ -0,0 +1,70 @@
+package shadow
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// Difference is a single field that differs between the stub and the upstream response.
+type Difference struct {
+	Field    string      `json:"field"`
+	Stub     interface{} `json:"stub"`
+	Upstream interface{} `json:"upstream"`
+}
+
+// diff compares two JSON documents, returning the differing fields as dotted paths. Arrays are compared as a whole.
+func diff(stub, upstream json.RawMessage, ignore map[string]bool) ([]Difference, error) {
+	var a, b interface{}
+
+	if err := json.Unmarshal(stub, &a); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(upstream, &b); err != nil {
+		return nil, err
+	}
+
+	var differences []Difference
+
+	diffValue("result", a, b, ignore, &differences)
+
+	return differences, nil
+}
+
+func diffValue(path string, a, b interface{}, ignore map[string]bool, differences *[]Difference) {
+	aMap, aIsMap := a.(map[string]interface{})
+	bMap, bIsMap := b.(map[string]interface{})
+
+	if !aIsMap || !bIsMap {
+		if !reflect.DeepEqual(a, b) {
+			*differences = append(*differences, Difference{Field: path, Stub: a, Upstream: b})
+		}
+
+		return
+	}
+
+	keys := make(map[string]bool, len(aMap)+len(bMap))
+	for k := range aMap {
+		keys[k] = true
+	}
+
+	for k := range bMap {
+		keys[k] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+
+	sort.Strings(sorted)
+
+	for _, k := range sorted {
+		if ignore[k] {
+			continue
+		}
+
+		diffValue(path+"."+k, aMap[k], bMap[k], ignore, differences)
+	}
+}
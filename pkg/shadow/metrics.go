@@ -0,0 +1,64 @@
+package shadow
+
+import "github.com/prometheus/client_golang/prometheus"
+
+type Metrics struct {
+	comparisons *prometheus.CounterVec
+	differences *prometheus.CounterVec
+	errors      *prometheus.CounterVec
+	dropped     prometheus.Counter
+}
+
+func NewMetrics(namespace string, reg prometheus.Registerer) Metrics {
+	m := Metrics{
+		comparisons: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "comparisons_total",
+			Help:      "Number of responses compared against the upstream execution client",
+		}, []string{"method", "result"}),
+		differences: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "differences_total",
+			Help:      "Number of differing fields between stub and upstream responses",
+		}, []string{"method", "field"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "upstream_errors_total",
+			Help:      "Number of requests the upstream execution client failed to answer",
+		}, []string{"method"}),
+		dropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "dropped_total",
+			Help:      "Number of requests not compared because the queue was full",
+		}),
+	}
+
+	reg.MustRegister(m.comparisons)
+	reg.MustRegister(m.differences)
+	reg.MustRegister(m.errors)
+	reg.MustRegister(m.dropped)
+
+	return m
+}
+
+func (m Metrics) ObserveComparison(method string, differences []Difference) {
+	if len(differences) == 0 {
+		m.comparisons.WithLabelValues(method, "match").Inc()
+
+		return
+	}
+
+	m.comparisons.WithLabelValues(method, "mismatch").Inc()
+
+	for _, d := range differences {
+		m.differences.WithLabelValues(method, d.Field).Inc()
+	}
+}
+
+func (m Metrics) ObserveUpstreamError(method string) {
+	m.errors.WithLabelValues(method).Inc()
+}
+
+func (m Metrics) ObserveDropped() {
+	m.dropped.Inc()
+}
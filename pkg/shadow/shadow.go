@@ -0,0 +1,172 @@
+package shadow
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/ethpandaops/stubbies/pkg/execution"
+	"github.com/ethpandaops/stubbies/pkg/rpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Backend answers every request with the stub logic while replaying it against a real execution
+// client in the background, reporting where the two responses differ.
+type Backend struct {
+	log logrus.FieldLogger
+	cfg Config
+
+	client  *rpc.Client
+	stub    *execution.Handler
+	metrics Metrics
+	ignore  map[string]bool
+
+	queue    chan *comparison
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+type comparison struct {
+	id      int
+	method  string
+	params  []*json.RawMessage
+	stub    *execution.Response
+	stubErr error
+}
+
+func NewBackend(log logrus.FieldLogger, conf *Config, stub *execution.Handler, reg prometheus.Registerer) (*Backend, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	client, err := rpc.NewClientFromConfig(&conf.Upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	ignore := make(map[string]bool, len(conf.IgnoreFields))
+	for _, field := range conf.IgnoreFields {
+		ignore[field] = true
+	}
+
+	return &Backend{
+		log:     log.WithField("module", "shadow"),
+		cfg:     *conf,
+		client:  client,
+		stub:    stub,
+		metrics: NewMetrics("shadow", reg),
+		ignore:  ignore,
+		queue:   make(chan *comparison, conf.QueueSize),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+func (b *Backend) Start(ctx context.Context) error {
+	b.log.WithField("target", b.client.URL()).Info("shadowing execution client")
+
+	if err := b.stub.Start(ctx); err != nil {
+		return err
+	}
+
+	// A single worker keeps requests in order, the upstream needs to see payloads before the forkchoice updates building on them.
+	go func() {
+		for {
+			select {
+			case <-b.done:
+				return
+			case c := <-b.queue:
+				b.compare(context.Background(), c)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop stops comparing, requests still queued are dropped. Calling it again is a no-op.
+func (b *Backend) Stop(ctx context.Context) error {
+	var err error
+
+	b.stopOnce.Do(func() {
+		close(b.done)
+
+		err = b.stub.Stop(ctx)
+	})
+
+	return err
+}
+
+func (b *Backend) SubscribeNewHeads() (<-chan *execution.ResultHeader, func()) {
+	return b.stub.SubscribeNewHeads()
+}
+
+func (b *Backend) LastEngineCall() time.Time {
+	return b.stub.LastEngineCall()
+}
+
+func (b *Backend) Request(ctx context.Context, id int, method string, params []*json.RawMessage) (*execution.Response, error) {
+	resp, err := b.stub.Request(ctx, id, method, params)
+
+	select {
+	case b.queue <- &comparison{id: id, method: method, params: params, stub: resp, stubErr: err}:
+	default:
+		b.metrics.ObserveDropped()
+	}
+
+	return resp, err
+}
+
+func (b *Backend) compare(ctx context.Context, c *comparison) {
+	upstream, err := b.client.Request(ctx, c.id, c.method, c.params)
+	if err != nil {
+		b.metrics.ObserveUpstreamError(c.method)
+		b.log.WithError(err).WithField("method", c.method).Warn("upstream execution client failed to answer")
+
+		return
+	}
+
+	differences, err := b.differences(c, upstream)
+	if err != nil {
+		b.log.WithError(err).WithField("method", c.method).Error("failed to compare responses")
+
+		return
+	}
+
+	b.metrics.ObserveComparison(c.method, differences)
+
+	for _, d := range differences {
+		b.log.WithFields(logrus.Fields{
+			"method":   c.method,
+			"field":    d.Field,
+			"stub":     d.Stub,
+			"upstream": d.Upstream,
+		}).Warn("stub response differs from upstream")
+	}
+}
+
+func (b *Backend) differences(c *comparison, upstream *execution.Response) ([]Difference, error) {
+	switch {
+	case c.stubErr != nil:
+		return []Difference{{Field: "error", Stub: c.stubErr.Error(), Upstream: upstream.Error}}, nil
+	case c.stub.Error != nil || upstream.Error != nil:
+		if c.stub.Error != nil && upstream.Error != nil && c.stub.Error.Code == upstream.Error.Code {
+			return nil, nil
+		}
+
+		return []Difference{{Field: "error", Stub: c.stub.Error, Upstream: upstream.Error}}, nil
+	}
+
+	stub, err := json.Marshal(c.stub.Result)
+	if err != nil {
+		return nil, err
+	}
+
+	upstreamResult, err := json.Marshal(upstream.Result)
+	if err != nil {
+		return nil, err
+	}
+
+	return diff(stub, upstreamResult, b.ignore)
+}
@@ -0,0 +1,267 @@
+package shadow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/creasty/defaults"
+	"github.com/ethpandaops/stubbies/pkg/execution"
+	"github.com/ethpandaops/stubbies/pkg/rpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		stub     string
+		upstream string
+		ignore   []string
+		expected []Difference
+	}{
+		{name: "equal", stub: `{"a":"0x1","b":[1,2]}`, upstream: `{"b":[1,2],"a":"0x1"}`},
+		{name: "scalar", stub: `"0x1"`, upstream: `"0x2"`, expected: []Difference{{Field: "result", Stub: "0x1", Upstream: "0x2"}}},
+		{
+			name:     "nested field",
+			stub:     `{"payloadStatus":{"status":"VALID","latestValidHash":"0x1"}}`,
+			upstream: `{"payloadStatus":{"status":"SYNCING","latestValidHash":"0x1"}}`,
+			expected: []Difference{{Field: "result.payloadStatus.status", Stub: "VALID", Upstream: "SYNCING"}},
+		},
+		{
+			name:     "missing fields in order",
+			stub:     `{"b":"0x1"}`,
+			upstream: `{"a":"0x1"}`,
+			expected: []Difference{
+				{Field: "result.a", Stub: nil, Upstream: "0x1"},
+				{Field: "result.b", Stub: "0x1", Upstream: nil},
+			},
+		},
+		{
+			name:     "arrays as a whole",
+			stub:     `{"a":[1,2]}`,
+			upstream: `{"a":[2,1]}`,
+			expected: []Difference{{Field: "result.a", Stub: []interface{}{1.0, 2.0}, Upstream: []interface{}{2.0, 1.0}}},
+		},
+		{
+			name:     "ignored fields at any depth",
+			stub:     `{"payloadId":"0x1","payloadStatus":{"status":"VALID","validationError":null}}`,
+			upstream: `{"payloadId":"0x2","payloadStatus":{"status":"VALID","validationError":"bad"}}`,
+			ignore:   []string{"payloadId", "validationError"},
+		},
+		{
+			name:     "object against null",
+			stub:     `{"a":"0x1"}`,
+			upstream: `null`,
+			expected: []Difference{{Field: "result", Stub: map[string]interface{}{"a": "0x1"}, Upstream: nil}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ignore := make(map[string]bool)
+			for _, field := range test.ignore {
+				ignore[field] = true
+			}
+
+			differences, err := diff(json.RawMessage(test.stub), json.RawMessage(test.upstream), ignore)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(differences, test.expected) {
+				t.Fatalf("differences are %+v, expected %+v", differences, test.expected)
+			}
+		})
+	}
+}
+
+func TestBackendDifferences(t *testing.T) {
+	serverError := &execution.ResponseError{Code: execution.ErrorCodeServerError, Message: "stub"}
+
+	tests := []struct {
+		name     string
+		stub     *execution.Response
+		stubErr  error
+		upstream *execution.Response
+		fields   []string
+	}{
+		{
+			name:     "results",
+			stub:     &execution.Response{Result: execution.ResultChainID("0x1")},
+			upstream: &execution.Response{Result: json.RawMessage(`"0x5"`)},
+			fields:   []string{"result"},
+		},
+		{
+			name:     "same error codes",
+			stub:     &execution.Response{Error: serverError},
+			upstream: &execution.Response{Error: &execution.ResponseError{Code: execution.ErrorCodeServerError, Message: "upstream"}},
+		},
+		{
+			name:     "different error codes",
+			stub:     &execution.Response{Error: serverError},
+			upstream: &execution.Response{Error: &execution.ResponseError{Code: execution.ErrorCodeMethodNotFound}},
+			fields:   []string{"error"},
+		},
+		{
+			name:     "only upstream errors",
+			stub:     &execution.Response{Result: false},
+			upstream: &execution.Response{Error: serverError},
+			fields:   []string{"error"},
+		},
+		{
+			name:     "stub failed",
+			stubErr:  errors.New("failed"),
+			upstream: &execution.Response{Result: false},
+			fields:   []string{"error"},
+		},
+	}
+
+	b := &Backend{ignore: map[string]bool{}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			differences, err := b.differences(&comparison{method: "eth_chainId", stub: test.stub, stubErr: test.stubErr}, test.upstream)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var fields []string
+			for _, d := range differences {
+				fields = append(fields, d.Field)
+			}
+
+			if !reflect.DeepEqual(fields, test.fields) {
+				t.Fatalf("differing fields are %v, expected %v", fields, test.fields)
+			}
+		})
+	}
+}
+
+// newTestBackend returns a started shadow of the stub comparing against an upstream answering the given results.
+func newTestBackend(t *testing.T, results map[string]string) *Backend {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		var req struct {
+			Method string `json:"method"`
+		}
+
+		_ = json.Unmarshal(body, &req)
+
+		result, ok := results[req.Method]
+		if !ok {
+			http.Error(w, "unexpected method", http.StatusInternalServerError)
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":` + result + `}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	stubConf := &execution.Config{}
+	if err := defaults.Set(stubConf); err != nil {
+		t.Fatal(err)
+	}
+
+	stub, err := execution.NewHandler(logrus.New(), stubConf, prometheus.NewRegistry(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &Config{}
+	if err := defaults.Set(conf); err != nil {
+		t.Fatal(err)
+	}
+
+	conf.Enabled = true
+	conf.Upstream = rpc.Config{Target: srv.URL, Timeout: 5 * time.Second}
+
+	b, err := NewBackend(logrus.New(), conf, stub, prometheus.NewRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = b.Stop(context.Background()) })
+
+	return b
+}
+
+func TestBackendCompare(t *testing.T) {
+	b := newTestBackend(t, map[string]string{
+		"eth_chainId": `"0x1"`,
+		"net_version": `"5"`,
+	})
+
+	tests := []struct {
+		method     string
+		result     string
+		difference string
+	}{
+		{method: "eth_chainId", result: "match"},
+		{method: "net_version", result: "mismatch", difference: "result"},
+		{method: "eth_syncing"},
+	}
+
+	for _, test := range tests {
+		resp, err := b.Request(context.Background(), 1, test.method, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The stub always answers, whatever the upstream returns.
+		if resp.Error != nil {
+			t.Fatalf("%s answered %+v", test.method, resp.Error)
+		}
+	}
+
+	// eth_syncing is not answered by the upstream, it is compared last as the queue is served in order.
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.ToFloat64(b.metrics.errors.WithLabelValues("eth_syncing")) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("requests were not compared")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, test := range tests {
+		if test.result == "" {
+			continue
+		}
+
+		if got := testutil.ToFloat64(b.metrics.comparisons.WithLabelValues(test.method, test.result)); got != 1 {
+			t.Errorf("%s was compared %v times with result %s", test.method, got, test.result)
+		}
+
+		if test.difference != "" {
+			if got := testutil.ToFloat64(b.metrics.differences.WithLabelValues(test.method, test.difference)); got != 1 {
+				t.Errorf("%s reported %v differences of %s", test.method, got, test.difference)
+			}
+		}
+	}
+}
+
+func TestBackendStopTwice(t *testing.T) {
+	b := newTestBackend(t, nil)
+
+	for i := 0; i < 2; i++ {
+		if err := b.Stop(context.Background()); err != nil {
+			t.Fatalf("stop %d failed: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// Package stubbiestest runs stubbies in-process, e.g. for go integration tests of consensus client code.
+package stubbiestest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/creasty/defaults"
+	exec "github.com/ethpandaops/stubbies/pkg/execution"
+	"github.com/ethpandaops/stubbies/pkg/server"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const localAddr = "127.0.0.1:0"
+
+// Stubbies is a running stubbies server listening on random local ports.
+type Stubbies struct {
+	// URL of the engine api, e.g. "http://127.0.0.1:41235".
+	URL string
+	// MetricsURL serves the metrics, health checks, /clients and /events.
+	MetricsURL string
+	// Registry holds the metrics of this instance only.
+	Registry *prometheus.Registry
+
+	server *server.Server
+	cancel context.CancelFunc
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// DefaultConfig returns the default stubbies config.
+func DefaultConfig() (*server.Config, error) {
+	conf := &server.Config{}
+	if err := defaults.Set(conf); err != nil {
+		return nil, err
+	}
+
+	return conf, nil
+}
+
+// New starts stubbies with conf, or the default config when conf is nil. The engine api and metrics listen on
+// random local ports. Tenants share the engine api port, so they need distinct path prefixes. log may be nil
+// to discard the logs.
+func New(log *logrus.Logger, conf *server.Config) (*Stubbies, error) {
+	if conf == nil {
+		defaultConf, err := DefaultConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		conf = defaultConf
+	}
+
+	if log == nil {
+		log = logrus.New()
+		log.SetOutput(io.Discard)
+	}
+
+	listenConf := *conf
+	listenConf.Addr = localAddr
+	listenConf.MetricsAddr = localAddr
+	listenConf.Tenants = make([]server.TenantConfig, len(conf.Tenants))
+
+	for i, tenant := range conf.Tenants {
+		tenant.Addr = ""
+		listenConf.Tenants[i] = tenant
+	}
+
+	reg := prometheus.NewRegistry()
+
+	srv, err := server.NewServerWithRegistry(log, &listenConf, reg)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := &Stubbies{
+		Registry: reg,
+		server:   srv,
+		cancel:   cancel,
+	}
+
+	if err := srv.Listen(ctx); err != nil {
+		s.Close()
+
+		return nil, err
+	}
+
+	scheme := "http"
+	if conf.TLS.Enabled() {
+		scheme = "https"
+	}
+
+	s.URL = scheme + "://" + srv.Addr()
+
+	scheme = "http"
+	if conf.MetricsTLS.Enabled() {
+		scheme = "https"
+	}
+
+	s.MetricsURL = scheme + "://" + srv.MetricsAddr()
+
+	return s, nil
+}
+
+// Execution returns the execution handler of the default tenant, to inspect its storage or change its
+// behaviour, e.g. through its Registry.
+func (s *Stubbies) Execution() *exec.Handler {
+	stub, _ := s.server.Execution(server.DefaultTenant)
+
+	return stub
+}
+
+// Tenant returns the execution handler of a tenant.
+func (s *Stubbies) Tenant(name string) (*exec.Handler, bool) {
+	return s.server.Execution(name)
+}
+
+// Handler returns the engine api handler, to serve it with httptest or call it directly.
+func (s *Stubbies) Handler() http.Handler {
+	return s.server.Handler()
+}
+
+// Server returns the underlying server.
+func (s *Stubbies) Server() *server.Server {
+	return s.server
+}
+
+// Close shuts stubbies down, draining in-flight requests for up to the configured shutdown timeout. It can be
+// called more than once, e.g. deferred and explicitly.
+func (s *Stubbies) Close() error {
+	s.closeOnce.Do(func() {
+		defer s.cancel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), s.server.Cfg.ShutdownTimeout)
+		defer cancel()
+
+		s.closeErr = s.server.Shutdown(ctx)
+	})
+
+	return s.closeErr
+}
@@ -0,0 +1,97 @@
+package stubbiestest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	exec "github.com/ethpandaops/stubbies/pkg/execution"
+	"github.com/ethpandaops/stubbies/pkg/webhook"
+)
+
+func call(t *testing.T, url, method string) *exec.Response {
+	t.Helper()
+
+	body := `{"jsonrpc":"2.0","id":1,"method":"` + method + `","params":[]}`
+
+	rsp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("%s failed: %v", method, err)
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		t.Fatalf("%s returned %s", method, rsp.Status)
+	}
+
+	resp := &exec.Response{}
+	if err := json.NewDecoder(rsp.Body).Decode(resp); err != nil {
+		t.Fatalf("failed to decode %s response: %v", method, err)
+	}
+
+	return resp
+}
+
+func TestStubbies(t *testing.T) {
+	conf, err := DefaultConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf.Execution.ChainID = "0x539"
+	conf.Divergence.Enabled = true
+	conf.Webhooks = []webhook.Config{{URL: "http://127.0.0.1:1", Timeout: 1, Retries: 0}}
+
+	stubbies, err := New(nil, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stubbies.Close()
+
+	if resp := call(t, stubbies.URL, "eth_chainId"); resp.Result != "0x539" {
+		t.Fatalf("eth_chainId returned %v, expected 0x539", resp.Result)
+	}
+
+	stubbies.Execution().Registry().Register("eth_getCode", func(ctx context.Context, call *exec.MethodCall) (interface{}, error) {
+		return "0x6080", nil
+	})
+
+	srv := httptest.NewServer(stubbies.Handler())
+	defer srv.Close()
+
+	if resp := call(t, srv.URL, "eth_getCode"); resp.Result != "0x6080" {
+		t.Fatalf("eth_getCode returned %v, expected the registered 0x6080", resp.Result)
+	}
+
+	if err := stubbies.Close(); err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+
+	// Stopping the server again must not panic.
+	if err := stubbies.Server().Shutdown(context.Background()); err != nil {
+		t.Fatalf("failed to shut down again: %v", err)
+	}
+}
+
+func TestStubbiesIsolated(t *testing.T) {
+	a, err := New(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	b, err := New(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	a.Execution().Registry().Unregister("eth_chainId")
+
+	if resp := call(t, b.URL, "eth_chainId"); resp.Result != "0x1" {
+		t.Fatalf("eth_chainId of the second instance returned %v, expected 0x1", resp.Result)
+	}
+}
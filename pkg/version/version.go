@@ -0,0 +1,53 @@
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+const Name = "stubbies"
+
+var (
+	// Release is the module version stubbies was built from, e.g. "v0.4.0".
+	Release = "dev"
+	// GitCommit is the VCS revision stubbies was built from.
+	GitCommit = "unknown"
+)
+
+func init() {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		Release = info.Main.Version
+	}
+
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" && setting.Value != "" {
+			GitCommit = setting.Value
+		}
+	}
+}
+
+// ShortCommit returns the first 8 characters of the git commit.
+func ShortCommit() string {
+	if len(GitCommit) > 8 {
+		return GitCommit[:8]
+	}
+
+	return GitCommit
+}
+
+// Full returns the release and commit, e.g. "v0.4.0-1a2b3c4d".
+func Full() string {
+	return fmt.Sprintf("%s-%s", Release, ShortCommit())
+}
+
+// ClientVersion returns a geth style client version string,
+// e.g. "stubbies/v0.4.0-1a2b3c4d/linux-amd64/go1.19.5".
+func ClientVersion() string {
+	return fmt.Sprintf("%s/%s/%s-%s/%s", Name, Full(), runtime.GOOS, runtime.GOARCH, runtime.Version())
+}
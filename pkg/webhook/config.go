@@ -0,0 +1,88 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/creasty/defaults"
+)
+
+// Config is a webhook target.
+type Config struct {
+	// Name identifies the target in logs and metrics. Defaults to the host of URL.
+	Name string `yaml:"name"`
+	// URL receives a JSON encoded Event per POST request.
+	URL string `yaml:"url"`
+	// Events limits the target to these event types. All events are sent when empty.
+	Events  []string          `yaml:"events"`
+	Timeout time.Duration     `yaml:"timeout" default:"5s"`
+	Headers map[string]string `yaml:"headers"`
+	// Retries is the number of times a failed delivery is retried. The wait between attempts starts at
+	// RetryInterval and doubles after every attempt.
+	Retries       int           `yaml:"retries" default:"3"`
+	RetryInterval time.Duration `yaml:"retryInterval" default:"1s"`
+}
+
+// UnmarshalYAML applies the config defaults to each target, they are not set for slice elements otherwise.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := defaults.Set(c); err != nil {
+		return err
+	}
+
+	type plain Config
+
+	return unmarshal((*plain)(c))
+}
+
+func (c *Config) Validate() error {
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("webhook url %q must be a http or https url", c.URL)
+	}
+
+	if c.Timeout <= 0 {
+		return errors.New("webhook timeout must be positive")
+	}
+
+	if c.Retries < 0 {
+		return errors.New("webhook retries must not be negative")
+	}
+
+	if c.Retries > 0 && c.RetryInterval <= 0 {
+		return errors.New("webhook retryInterval must be positive")
+	}
+
+	for _, event := range c.Events {
+		if !knownEventType(event) {
+			return fmt.Errorf("webhook %s: unknown event %q, must be one of %s", c.name(), event, strings.Join(EventTypes, ", "))
+		}
+	}
+
+	return nil
+}
+
+func knownEventType(eventType string) bool {
+	for _, known := range EventTypes {
+		if eventType == known {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Config) name() string {
+	if c.Name != "" {
+		return c.Name
+	}
+
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return c.URL
+	}
+
+	return u.Host
+}
@@ -0,0 +1,44 @@
+package webhook
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	resultDelivered = "delivered"
+	resultFailed    = "failed"
+	resultDropped   = "dropped"
+)
+
+type Metrics struct {
+	deliveries *prometheus.CounterVec
+	retries    *prometheus.CounterVec
+}
+
+func NewMetrics(namespace string, reg prometheus.Registerer) Metrics {
+	m := Metrics{
+		deliveries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "deliveries_total",
+			Help:      "Number of webhook events by target, type and result",
+		}, []string{"target", "type", "result"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "retries_total",
+			Help:      "Number of retried webhook deliveries",
+		}, []string{"target", "type"}),
+	}
+
+	reg.MustRegister(m.deliveries)
+	reg.MustRegister(m.retries)
+
+	return m
+}
+
+func (m Metrics) ObserveDelivery(target, eventType, result string) {
+	m.deliveries.WithLabelValues(target, eventType, result).Inc()
+}
+
+func (m Metrics) ObserveRetry(target, eventType string) {
+	m.retries.WithLabelValues(target, eventType).Inc()
+}
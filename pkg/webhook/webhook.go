@@ -0,0 +1,237 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// queueSize is the number of events buffered per target. Events are dropped when the queue is full.
+const queueSize = 100
+
+// Event types sent by stubbies.
+const (
+	EventReorg                 = "reorg"
+	EventInvalidStatus         = "invalid_status"
+	EventUnknownParent         = "unknown_parent"
+	EventConsensusClientSilent = "consensus_client_silent"
+	EventFinalizedReverted     = "finalized_reverted"
+	EventForkchoiceDiverged    = "forkchoice_diverged"
+	EventForkchoiceConverged   = "forkchoice_converged"
+)
+
+// EventTypes are all event types a target can subscribe to.
+var EventTypes = []string{
+	EventReorg,
+	EventInvalidStatus,
+	EventUnknownParent,
+	EventConsensusClientSilent,
+	EventFinalizedReverted,
+	EventForkchoiceDiverged,
+	EventForkchoiceConverged,
+}
+
+// Event is the body of a webhook call.
+type Event struct {
+	Type    string      `json:"type"`
+	Time    time.Time   `json:"time"`
+	Tenant  string      `json:"tenant"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// NewEvent returns a new Event of the given type that happened now.
+func NewEvent(eventType, message string, data interface{}) *Event {
+	return &Event{
+		Type:    eventType,
+		Time:    time.Now().UTC(),
+		Message: message,
+		Data:    data,
+	}
+}
+
+// Dispatcher delivers events to all webhook targets subscribed to them. Every target is served
+// in the background, in the order events were sent.
+type Dispatcher struct {
+	log    logrus.FieldLogger
+	tenant string
+
+	targets []*target
+	metrics Metrics
+
+	stopOnce sync.Once
+}
+
+type target struct {
+	log    logrus.FieldLogger
+	cfg    Config
+	name   string
+	events map[string]bool
+
+	client *http.Client
+
+	queue chan *Event
+	done  chan struct{}
+}
+
+// NewDispatcher returns a new Dispatcher stamping events with tenant. A Dispatcher without targets drops all events.
+func NewDispatcher(log logrus.FieldLogger, confs []Config, tenant string, reg prometheus.Registerer) (*Dispatcher, error) {
+	d := &Dispatcher{
+		log:     log.WithField("module", "webhook"),
+		tenant:  tenant,
+		metrics: NewMetrics("webhook", reg),
+	}
+
+	for i := range confs {
+		conf := confs[i]
+
+		if err := conf.Validate(); err != nil {
+			return nil, err
+		}
+
+		events := make(map[string]bool, len(conf.Events))
+		for _, event := range conf.Events {
+			events[event] = true
+		}
+
+		d.targets = append(d.targets, &target{
+			log:    d.log.WithField("target", conf.name()),
+			cfg:    conf,
+			name:   conf.name(),
+			events: events,
+			client: &http.Client{Timeout: conf.Timeout},
+			queue:  make(chan *Event, queueSize),
+			done:   make(chan struct{}),
+		})
+	}
+
+	return d, nil
+}
+
+func (d *Dispatcher) Start(ctx context.Context) {
+	for _, t := range d.targets {
+		go d.run(t)
+	}
+}
+
+// Stop stops delivering events. Calling it again is a no-op.
+func (d *Dispatcher) Stop() {
+	d.stopOnce.Do(func() {
+		for _, t := range d.targets {
+			close(t.done)
+		}
+	})
+}
+
+// Subscribed returns true if any target receives events of eventType.
+func (d *Dispatcher) Subscribed(eventType string) bool {
+	for _, t := range d.targets {
+		if t.subscribed(eventType) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Send queues an event for delivery to every subscribed target without blocking.
+func (d *Dispatcher) Send(event *Event) {
+	event.Tenant = d.tenant
+
+	for _, t := range d.targets {
+		if !t.subscribed(event.Type) {
+			continue
+		}
+
+		select {
+		case t.queue <- event:
+		default:
+			d.metrics.ObserveDelivery(t.name, event.Type, resultDropped)
+			t.log.WithField("type", event.Type).Warn("Webhook queue is full, dropping event")
+		}
+	}
+}
+
+func (t *target) subscribed(eventType string) bool {
+	return len(t.events) == 0 || t.events[eventType]
+}
+
+func (d *Dispatcher) run(t *target) {
+	for {
+		select {
+		case <-t.done:
+			return
+		case event := <-t.queue:
+			if err := d.deliver(t, event); err != nil {
+				d.metrics.ObserveDelivery(t.name, event.Type, resultFailed)
+				t.log.WithError(err).WithField("type", event.Type).Warn("Failed to deliver webhook")
+
+				continue
+			}
+
+			d.metrics.ObserveDelivery(t.name, event.Type, resultDelivered)
+		}
+	}
+}
+
+// deliver posts event to t, retrying with an exponential backoff until it succeeds, runs out of retries
+// or the dispatcher is stopped.
+func (d *Dispatcher) deliver(t *target, event *Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	wait := t.cfg.RetryInterval
+
+	for attempt := 0; ; attempt++ {
+		err = t.post(body)
+		if err == nil || attempt >= t.cfg.Retries {
+			return err
+		}
+
+		d.metrics.ObserveRetry(t.name, event.Type)
+		t.log.WithError(err).WithField("type", event.Type).Debug("Retrying webhook delivery")
+
+		select {
+		case <-t.done:
+			return err
+		case <-time.After(wait):
+		}
+
+		wait *= 2
+	}
+}
+
+func (t *target) post(body []byte) error {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, t.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	for header, value := range t.cfg.Headers {
+		req.Header.Set(header, value)
+	}
+
+	rsp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d", rsp.StatusCode)
+	}
+
+	return nil
+}
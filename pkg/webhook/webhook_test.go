@@ -0,0 +1,244 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+)
+
+// receiver is a webhook target failing the first failures requests.
+type receiver struct {
+	failures int
+
+	mu     sync.Mutex
+	events []*Event
+	// headers of the last request.
+	headers http.Header
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var event Event
+	if err := json.NewDecoder(req.Body).Decode(&event); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	r.events = append(r.events, &event)
+	r.headers = req.Header.Clone()
+
+	if len(r.events) <= r.failures {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}
+}
+
+func (r *receiver) received() []*Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*Event(nil), r.events...)
+}
+
+func newTestDispatcher(t *testing.T, confs ...Config) *Dispatcher {
+	t.Helper()
+
+	d, err := NewDispatcher(logrus.New(), confs, "tenant-a", prometheus.NewRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.Start(context.Background())
+	t.Cleanup(d.Stop)
+
+	return d
+}
+
+// waitFor waits until the target finished delivering count events.
+func waitFor(t *testing.T, d *Dispatcher, target, eventType string, count float64) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		done := testutil.ToFloat64(d.metrics.deliveries.WithLabelValues(target, eventType, resultDelivered)) +
+			testutil.ToFloat64(d.metrics.deliveries.WithLabelValues(target, eventType, resultFailed))
+		if done >= count {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("%v of %v events were delivered", done, count)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDispatcherRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		retries  int
+		attempts int
+		result   string
+	}{
+		{name: "delivered", failures: 0, retries: 3, attempts: 1, result: resultDelivered},
+		{name: "delivered after retries", failures: 2, retries: 3, attempts: 3, result: resultDelivered},
+		{name: "delivered on the last retry", failures: 3, retries: 3, attempts: 4, result: resultDelivered},
+		{name: "failed after retries", failures: 5, retries: 3, attempts: 4, result: resultFailed},
+		{name: "failed without retries", failures: 1, retries: 0, attempts: 1, result: resultFailed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &receiver{failures: test.failures}
+
+			srv := httptest.NewServer(r)
+			t.Cleanup(srv.Close)
+
+			d := newTestDispatcher(t, Config{
+				Name:          "receiver",
+				URL:           srv.URL,
+				Timeout:       time.Second,
+				Headers:       map[string]string{"Authorization": "Bearer token"},
+				Retries:       test.retries,
+				RetryInterval: time.Millisecond,
+			})
+
+			d.Send(NewEvent(EventReorg, "reorged", map[string]int{"depth": 2}))
+			waitFor(t, d, "receiver", EventReorg, 1)
+
+			events := r.received()
+			if len(events) != test.attempts {
+				t.Fatalf("received %d attempts, expected %d", len(events), test.attempts)
+			}
+
+			for _, event := range events {
+				if event.Type != EventReorg || event.Tenant != "tenant-a" || event.Message != "reorged" {
+					t.Fatalf("received %+v", event)
+				}
+			}
+
+			if auth := r.headers.Get("Authorization"); auth != "Bearer token" {
+				t.Errorf("authorization header is %q", auth)
+			}
+
+			if got := testutil.ToFloat64(d.metrics.deliveries.WithLabelValues("receiver", EventReorg, test.result)); got != 1 {
+				t.Errorf("%v deliveries with result %s, expected 1", got, test.result)
+			}
+
+			if got := testutil.ToFloat64(d.metrics.retries.WithLabelValues("receiver", EventReorg)); got != float64(test.attempts-1) {
+				t.Errorf("%v retries, expected %d", got, test.attempts-1)
+			}
+		})
+	}
+}
+
+func TestDispatcherSubscriptions(t *testing.T) {
+	all, reorgs := &receiver{}, &receiver{}
+
+	allSrv, reorgsSrv := httptest.NewServer(all), httptest.NewServer(reorgs)
+	t.Cleanup(allSrv.Close)
+	t.Cleanup(reorgsSrv.Close)
+
+	d := newTestDispatcher(t,
+		Config{Name: "all", URL: allSrv.URL, Timeout: time.Second},
+		Config{Name: "reorgs", URL: reorgsSrv.URL, Timeout: time.Second, Events: []string{EventReorg}},
+	)
+
+	if !d.Subscribed(EventInvalidStatus) {
+		t.Fatal("target without events is not subscribed to every event")
+	}
+
+	d.Send(NewEvent(EventInvalidStatus, "invalid", nil))
+	d.Send(NewEvent(EventReorg, "reorged", nil))
+
+	waitFor(t, d, "all", EventReorg, 1)
+	waitFor(t, d, "reorgs", EventReorg, 1)
+
+	tests := []struct {
+		receiver *receiver
+		expected []string
+	}{
+		{receiver: all, expected: []string{EventInvalidStatus, EventReorg}},
+		{receiver: reorgs, expected: []string{EventReorg}},
+	}
+
+	for i, test := range tests {
+		events := test.receiver.received()
+		if len(events) != len(test.expected) {
+			t.Fatalf("target %d received %d events, expected %v", i, len(events), test.expected)
+		}
+
+		for j, event := range events {
+			if event.Type != test.expected[j] {
+				t.Errorf("target %d received %s, expected %s", i, event.Type, test.expected[j])
+			}
+		}
+	}
+}
+
+func TestDispatcherStopDuringRetry(t *testing.T) {
+	r := &receiver{failures: 100}
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	d := newTestDispatcher(t, Config{Name: "receiver", URL: srv.URL, Timeout: time.Second, Retries: 3, RetryInterval: time.Hour})
+
+	d.Send(NewEvent(EventReorg, "reorged", nil))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.ToFloat64(d.metrics.retries.WithLabelValues("receiver", EventReorg)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("delivery was not retried")
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	d.Stop()
+	d.Stop()
+
+	// The hour long wait is abandoned and the event counted as failed.
+	waitFor(t, d, "receiver", EventReorg, 1)
+
+	if got := testutil.ToFloat64(d.metrics.deliveries.WithLabelValues("receiver", EventReorg, resultFailed)); got != 1 {
+		t.Fatalf("%v failed deliveries, expected 1", got)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		conf Config
+		err  bool
+	}{
+		{name: "valid", conf: Config{URL: "https://example.com/hook", Timeout: time.Second, Retries: 1, RetryInterval: time.Second, Events: []string{EventReorg}}},
+		{name: "no retries", conf: Config{URL: "http://example.com", Timeout: time.Second}},
+		{name: "not http", conf: Config{URL: "ftp://example.com", Timeout: time.Second}, err: true},
+		{name: "no timeout", conf: Config{URL: "http://example.com"}, err: true},
+		{name: "negative retries", conf: Config{URL: "http://example.com", Timeout: time.Second, Retries: -1}, err: true},
+		{name: "retries without interval", conf: Config{URL: "http://example.com", Timeout: time.Second, Retries: 1}, err: true},
+		{name: "unknown event", conf: Config{URL: "http://example.com", Timeout: time.Second, Events: []string{"reorged"}}, err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.conf.Validate(); (err != nil) != test.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}